@@ -0,0 +1,317 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/decred/dcrutil"
+)
+
+// stakeholderConfig houses the configuration of a simulated stakeholder that
+// makes its own ticket purchase decisions.
+type stakeholderConfig struct {
+	// Name identifies the stakeholder in the results.
+	Name string `json:"name"`
+
+	// Strategy is the purchasing strategy of the stakeholder.  It must be
+	// one of solo, pool, or whale.
+	Strategy string `json:"strategy"`
+
+	// Balance is the initial spendable balance of the stakeholder in DCR.
+	Balance float64 `json:"balance"`
+
+	// Income is the amount of DCR the stakeholder receives every block
+	// from sources other than staking, for example, mining or an exchange.
+	Income float64 `json:"income"`
+
+	// MinYield is the minimum yield a ticket must produce for the
+	// stakeholder to consider purchasing it.
+	MinYield float64 `json:"minyield"`
+
+	// Budget is the maximum amount of DCR the stakeholder spends on tickets
+	// per ticket price window.  For the pool strategy, it is instead the
+	// fraction of the current balance spent per window.
+	Budget float64 `json:"budget"`
+
+	// MaxVWAP is the maximum fraction of the VWAP the ticket price may be
+	// for the whale strategy to purchase tickets.
+	MaxVWAP float64 `json:"maxvwap"`
+}
+
+// defaultPopulation is the population of stakeholders used when the default
+// population is requested.  It is intended to be illustrative of a mix of
+// purchasing behavior rather than an accurate model of the mainnet population.
+var defaultPopulation = []stakeholderConfig{
+	{Name: "solo-small", Strategy: "solo", Balance: 200000, Income: 2,
+		MinYield: 0.02, Budget: 20000},
+	{Name: "solo-large", Strategy: "solo", Balance: 500000, Income: 5,
+		MinYield: 0.03, Budget: 50000},
+	{Name: "pool-a", Strategy: "pool", Balance: 1000000, Income: 5,
+		MinYield: 0.025, Budget: 0.1},
+	{Name: "pool-b", Strategy: "pool", Balance: 500000, Income: 2,
+		MinYield: 0.03, Budget: 0.1},
+	{Name: "whale", Strategy: "whale", Balance: 2000000, Income: 0,
+		MinYield: 0.02, Budget: 150000, MaxVWAP: 0.9},
+}
+
+// stakeholder is a simulated agent that holds a balance and makes its own
+// ticket purchase decisions according to its strategy.
+type stakeholder struct {
+	name      string
+	strategy  string
+	balance   dcrutil.Amount
+	income    dcrutil.Amount
+	minYield  float64
+	budget    float64
+	maxVWAP   float64
+	allowance dcrutil.Amount
+}
+
+// newStakeholder returns a new stakeholder created according to the passed
+// configuration.
+func newStakeholder(cfg *stakeholderConfig) (*stakeholder, error) {
+	switch cfg.Strategy {
+	case "solo", "pool", "whale":
+	default:
+		return nil, fmt.Errorf("stakeholder %q has unknown strategy %q "+
+			"-- available strategies are solo, pool, and whale",
+			cfg.Name, cfg.Strategy)
+	}
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("stakeholders must have a name")
+	}
+	if cfg.Balance < 0 || cfg.Income < 0 || cfg.Budget < 0 {
+		return nil, fmt.Errorf("stakeholder %q must not have a negative "+
+			"balance, income, or budget", cfg.Name)
+	}
+	if cfg.Strategy == "pool" {
+		if err := validateRate("pool budget", cfg.Budget); err != nil {
+			return nil, fmt.Errorf("stakeholder %q: %v", cfg.Name, err)
+		}
+	}
+	if cfg.Strategy == "whale" && cfg.MaxVWAP <= 0 {
+		return nil, fmt.Errorf("whale stakeholder %q must have a maxvwap "+
+			"greater than zero", cfg.Name)
+	}
+
+	balance, err := dcrutil.NewAmount(cfg.Balance)
+	if err != nil {
+		return nil, err
+	}
+	income, err := dcrutil.NewAmount(cfg.Income)
+	if err != nil {
+		return nil, err
+	}
+	return &stakeholder{
+		name:     cfg.Name,
+		strategy: cfg.Strategy,
+		balance:  balance,
+		income:   income,
+		minYield: cfg.MinYield,
+		budget:   cfg.Budget,
+		maxVWAP:  cfg.MaxVWAP,
+	}, nil
+}
+
+// windowBudget returns the maximum amount the stakeholder spends on tickets in
+// a ticket price window.
+func (a *stakeholder) windowBudget() dcrutil.Amount {
+	if a.strategy == "pool" {
+		return dcrutil.Amount(float64(a.balance) * a.budget)
+	}
+	return dcrutil.Amount(a.budget * dcrutil.AtomsPerCoin)
+}
+
+// wantedTickets returns the number of tickets the stakeholder wants to purchase
+// in the next block given the ticket price, the yield a ticket would produce,
+// and the VWAP of the previous windows.
+//
+// Solo stakeholders and stake pools spread their budget evenly over each
+// window, while pools also scale their budget with their balance.  Whales
+// accumulate their budget and only purchase once the price drops far enough
+// below the VWAP.
+func (a *stakeholder) wantedTickets(ticketPrice int64, yield float64, vwap int64, windowSize int32) int {
+	// Accumulate the budget for this block, but never allow more than a
+	// full window's worth to accumulate.
+	windowBudget := a.windowBudget()
+	a.allowance += windowBudget / dcrutil.Amount(windowSize)
+	if a.allowance > windowBudget {
+		a.allowance = windowBudget
+	}
+
+	if yield < a.minYield {
+		return 0
+	}
+	if a.strategy == "whale" && vwap > 0 &&
+		float64(ticketPrice) > float64(vwap)*a.maxVWAP {
+		return 0
+	}
+
+	spendable := a.allowance
+	if spendable > a.balance {
+		spendable = a.balance
+	}
+	return int(int64(spendable) / ticketPrice)
+}
+
+// agentPopulation is a population of stakeholders whose individual purchase
+// decisions determine the number of tickets purchased in each block.
+type agentPopulation struct {
+	agents []*stakeholder
+}
+
+// newAgentPopulation returns a new population of stakeholders created according
+// to the passed configurations.
+func newAgentPopulation(cfgs []stakeholderConfig) (*agentPopulation, error) {
+	if len(cfgs) == 0 {
+		return nil, fmt.Errorf("the stakeholder population is empty")
+	}
+	names := make(map[string]struct{}, len(cfgs))
+	agents := make([]*stakeholder, 0, len(cfgs))
+	for i := range cfgs {
+		agent, err := newStakeholder(&cfgs[i])
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := names[agent.name]; ok {
+			return nil, fmt.Errorf("duplicate stakeholder name %q",
+				agent.name)
+		}
+		names[agent.name] = struct{}{}
+		agents = append(agents, agent)
+	}
+	return &agentPopulation{agents: agents}, nil
+}
+
+// loadStakeholderConfigs loads the stakeholder configurations from the JSON
+// file at the provided path, which must contain an array of them.  The
+// configurations of the default population are returned when the path is
+// "default".  The configurations are validated by creating a population from
+// them.
+func loadStakeholderConfigs(path string) ([]stakeholderConfig, error) {
+	if path == "default" {
+		return defaultPopulation, nil
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfgs []stakeholderConfig
+	if err := json.Unmarshal(contents, &cfgs); err != nil {
+		return nil, fmt.Errorf("unable to parse stakeholders file %q: %v",
+			path, err)
+	}
+	if _, err := newAgentPopulation(cfgs); err != nil {
+		return nil, err
+	}
+	return cfgs, nil
+}
+
+// purchaseTickets determines how many tickets each stakeholder purchases in the
+// block at the given height, deducts the cost from their balances, and returns
+// the index of the purchasing stakeholder for each ticket.  Vote rewards and
+// refunds are credited back to the stakeholders as they mature.  No more than the
+// passed maximum number of tickets are purchased.
+//
+// The number of tickets each stakeholder wants is multiplied by the passed
+// demand factor, which is used to script demand spikes and drops, although
+// stakeholders never want more tickets than their balance can pay for.
+//
+// When the stakeholders want more tickets than the maximum, the available
+// tickets are allocated one at a time in a random order so that no stakeholder
+// is systematically favored.
+func (p *agentPopulation) purchaseTickets(s *simulator, nextHeight int32, ticketPrice int64, maxTickets int, demandFactor float64) []uint32 {
+	// All stakeholders receive their income regardless of whether or not
+	// they purchase any tickets.
+	for _, agent := range p.agents {
+		agent.balance += agent.income
+	}
+	if maxTickets <= 0 || ticketPrice <= 0 {
+		return nil
+	}
+
+	// Determine how many tickets each stakeholder wants.
+	windowSize := int32(s.params.StakeDiffWindowSize)
+	yield := float64(s.perVoteSubsidy(nextHeight)) / float64(ticketPrice)
+	vwap := s.calcPrevVWAP(s.tip)
+	wanted := make([]int, len(p.agents))
+	var totalWanted int
+	for i, agent := range p.agents {
+		wanted[i] = agent.wantedTickets(ticketPrice, yield, vwap,
+			windowSize)
+		if demandFactor != 1 {
+			scaled := int(float64(wanted[i])*demandFactor + 0.5)
+			affordable := int(int64(agent.balance) / ticketPrice)
+			if scaled > affordable {
+				scaled = affordable
+			}
+			if scaled < 0 {
+				scaled = 0
+			}
+			wanted[i] = scaled
+		}
+		totalWanted += wanted[i]
+	}
+
+	// Allocate the tickets in a random order when the stakeholders want
+	// more than are available.
+	owners := make([]uint32, 0, maxTickets)
+	if totalWanted <= maxTickets {
+		for i, n := range wanted {
+			for j := 0; j < n; j++ {
+				owners = append(owners, uint32(i))
+			}
+		}
+	} else {
+		order := s.rng.Perm(len(p.agents))
+		for len(owners) < maxTickets {
+			for _, i := range order {
+				if wanted[i] > 0 && len(owners) < maxTickets {
+					owners = append(owners, uint32(i))
+					wanted[i]--
+				}
+			}
+		}
+	}
+
+	// Deduct the cost of the purchased tickets from the balance and
+	// allowance of the purchasing stakeholders.
+	price := dcrutil.Amount(ticketPrice)
+	for _, i := range owners {
+		p.agents[i].balance -= price
+		p.agents[i].allowance -= price
+	}
+	return owners
+}
+
+// bootstrapOwner returns the owner index used for the tickets purchased to ramp
+// up the simulation prior to the stake validation height, which do not belong
+// to any of the stakeholders.
+func (p *agentPopulation) bootstrapOwner() uint32 {
+	return uint32(len(p.agents))
+}
+
+// whaleOwner returns the owner index used for the tickets purchased by scripted
+// whale events, which do not belong to any of the stakeholders.
+func (p *agentPopulation) whaleOwner() uint32 {
+	return uint32(len(p.agents)) + 1
+}
+
+// results returns the per-stakeholder details shown in the simulation results.
+func (p *agentPopulation) results() []map[string]string {
+	results := make([]map[string]string, 0, len(p.agents))
+	for _, agent := range p.agents {
+		results = append(results, map[string]string{
+			"Name":     agent.name,
+			"Strategy": agent.strategy,
+			"Balance":  agent.balance.String(),
+		})
+	}
+	return results
+}
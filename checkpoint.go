@@ -0,0 +1,531 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+
+	"github.com/davecgh/dcrstakesim/internal/tickettreap"
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrutil"
+)
+
+// checkpointVersion is the version of the checkpoint file format.  It must be
+// increased whenever the format changes in a way that is not compatible with
+// previous versions.
+const checkpointVersion = 1
+
+// countingSource is a source of pseudo-random numbers that counts how many
+// values it has produced.  It allows the state of a random number generator to
+// be saved in a checkpoint and restored later by producing the same number of
+// values from a new source created with the same seed.
+type countingSource struct {
+	src   rand.Source64
+	draws uint64
+}
+
+// newCountingSource returns a new counting source initialized with the passed
+// seed.
+func newCountingSource(seed int64) *countingSource {
+	return &countingSource{src: rand.NewSource(seed).(rand.Source64)}
+}
+
+// Int63 returns a non-negative pseudo-random 63-bit integer as an int64.
+//
+// This is part of the rand.Source interface.
+func (c *countingSource) Int63() int64 {
+	c.draws++
+	return c.src.Int63()
+}
+
+// Uint64 returns a pseudo-random 64-bit value as a uint64.
+//
+// This is part of the rand.Source64 interface.
+func (c *countingSource) Uint64() uint64 {
+	c.draws++
+	return c.src.Uint64()
+}
+
+// Seed uses the provided seed value to initialize the source to a
+// deterministic state and resets the count.
+//
+// This is part of the rand.Source interface.
+func (c *countingSource) Seed(seed int64) {
+	c.src.Seed(seed)
+	c.draws = 0
+}
+
+// skip advances the source by the passed number of values.
+func (c *countingSource) skip(draws uint64) {
+	for i := uint64(0); i < draws; i++ {
+		c.Int63()
+	}
+}
+
+// checkpointTicket is the serialized form of a stake ticket.
+type checkpointTicket struct {
+	Hash         chainhash.Hash
+	BlockHeight  int32
+	Price        dcrutil.Amount
+	WinHeight    int32
+	RevokeHeight int32
+	Owner        uint32
+}
+
+// checkpointBlock is the serialized form of a block node.  Tickets are
+// referenced by their index in the tickets of the checkpoint.
+type checkpointBlock struct {
+	Header          []byte
+	TicketPrice     int64
+	RegularSubsidy  dcrutil.Amount
+	PoolSize        uint32
+	FinalState      [6]byte
+	TotalSupply     dcrutil.Amount
+	SpendableSupply dcrutil.Amount
+	NumVoters       uint16
+	TicketsAdded    []uint32
+	TicketsVoted    []uint32
+	TicketsRevoked  []uint32
+}
+
+// checkpointOwner is the serialized form of the accounting of a single owner of
+// tickets.
+type checkpointOwner struct {
+	Purchased   uint32
+	LiveTickets uint32
+	Invested    dcrutil.Amount
+	VoteRewards dcrutil.Amount
+	Returned    dcrutil.Amount
+	Refunds     dcrutil.Amount
+	Locked      dcrutil.Amount
+	Abandoned   dcrutil.Amount
+}
+
+// checkpointShareSample is the serialized form of a pool share sample.
+type checkpointShareSample struct {
+	Height int32
+	Shares []float64
+}
+
+// checkpointAgent is the serialized form of the mutable state of a simulated
+// stakeholder.  The rest of the stakeholder is defined by its configuration.
+type checkpointAgent struct {
+	Name      string
+	Balance   dcrutil.Amount
+	Allowance dcrutil.Amount
+}
+
+// simCheckpoint houses the entire state of a simulator as of its tip so the
+// simulation can be resumed from it later, potentially with different behavior
+// such as another demand model or stake difficulty algorithm.
+//
+// The configuration of the models is not part of the checkpoint since it is
+// provided when resuming, however, the state the models accumulate is.
+type simCheckpoint struct {
+	Version   int
+	Network   string
+	Params    map[string]int64
+	Algorithm string
+	Seed      int64
+	RNGDraws  uint64
+
+	Tickets          []checkpointTicket
+	Blocks           []checkpointBlock
+	RootHeight       int32
+	ImmatureTickets  []uint32
+	LiveTickets      []uint32
+	ExpireHeights    map[int32][]uint32
+	ExpiredTickets   []uint32
+	MissedTickets    []uint32
+	UnrevokedTickets []uint32
+	WonTickets       []uint32
+
+	TotalSupply        dcrutil.Amount
+	SpendableSupply    dcrutil.Amount
+	MaturingSupply     map[int32]dcrutil.Amount
+	InvalidatedBlocks  uint32
+	InvalidatedSubsidy dcrutil.Amount
+
+	DemandPerWindow int32
+	DemandComputed  bool
+	PendingMisses   float64
+	OutageEnd       int32
+
+	Agents        []checkpointAgent
+	Owners        []checkpointOwner
+	OwnerMaturing map[int32]map[uint32]dcrutil.Amount
+	ShareHistory  []checkpointShareSample
+}
+
+// paramsSnapshot returns the values of all of the numeric chain parameters that
+// may be overridden by a custom parameters file keyed by their name.  It is
+// used to ensure a checkpoint is only resumed with the parameters it was
+// created with.
+func paramsSnapshot(params *chaincfg.Params) map[string]int64 {
+	snapshot := make(map[string]int64)
+	overridesType := reflect.TypeOf(paramsOverrides{})
+	paramsValue := reflect.ValueOf(params).Elem()
+	for i := 0; i < overridesType.NumField(); i++ {
+		name := overridesType.Field(i).Name
+		field := paramsValue.FieldByName(name)
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+			reflect.Int64:
+			snapshot[name] = field.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+			reflect.Uint64:
+			snapshot[name] = int64(field.Uint())
+		}
+	}
+	return snapshot
+}
+
+// checkpoint returns the entire state of the simulator as of its tip.
+func (s *simulator) checkpoint() *simCheckpoint {
+	cp := &simCheckpoint{
+		Version:            checkpointVersion,
+		Network:            s.params.Name,
+		Params:             paramsSnapshot(s.params),
+		Algorithm:          s.algoName,
+		Seed:               s.seed,
+		RNGDraws:           s.rngSource.draws,
+		ExpireHeights:      make(map[int32][]uint32),
+		TotalSupply:        s.totalSupply,
+		SpendableSupply:    s.spendableSupply,
+		MaturingSupply:     s.maturingSupply,
+		InvalidatedBlocks:  s.invalidatedBlocks,
+		InvalidatedSubsidy: s.invalidatedSubsidy,
+		DemandPerWindow:    s.demandPerWindow,
+		DemandComputed:     s.demandComputed,
+		OwnerMaturing:      s.ownership.maturing,
+	}
+	switch m := s.voteModel.(type) {
+	case *fixedMissVoteModel:
+		cp.PendingMisses = m.pendingFraction
+	case *outageVoteModel:
+		cp.OutageEnd = m.outageEnd
+	}
+
+	// Assign every ticket an index the first time it is referenced and
+	// reference it by that index everywhere else so that tickets which are
+	// shared between the blocks and the various pools remain shared once
+	// restored.
+	ticketIndices := make(map[*stakeTicket]uint32)
+	indexOf := func(ticket *stakeTicket) uint32 {
+		if idx, ok := ticketIndices[ticket]; ok {
+			return idx
+		}
+		idx := uint32(len(cp.Tickets))
+		ticketIndices[ticket] = idx
+		cp.Tickets = append(cp.Tickets, checkpointTicket{
+			Hash:         ticket.hash,
+			BlockHeight:  ticket.blockHeight,
+			Price:        ticket.price,
+			WinHeight:    ticket.winHeight,
+			RevokeHeight: ticket.revokeHeight,
+			Owner:        ticket.owner,
+		})
+		return idx
+	}
+	indices := func(tickets []*stakeTicket) []uint32 {
+		if len(tickets) == 0 {
+			return nil
+		}
+		result := make([]uint32, 0, len(tickets))
+		for _, ticket := range tickets {
+			result = append(result, indexOf(ticket))
+		}
+		return result
+	}
+
+	if s.root != nil {
+		cp.RootHeight = s.root.height
+	}
+	for node := s.root; node != nil; node = node.next {
+		cp.Blocks = append(cp.Blocks, checkpointBlock{
+			Header:          node.header,
+			TicketPrice:     node.ticketPrice,
+			RegularSubsidy:  node.regularSubsidy,
+			PoolSize:        node.poolSize,
+			FinalState:      node.finalState,
+			TotalSupply:     node.totalSupply,
+			SpendableSupply: node.spendableSupply,
+			NumVoters:       node.numVoters,
+			TicketsAdded:    indices(node.ticketsAdded),
+			TicketsVoted:    indices(node.ticketsVoted),
+			TicketsRevoked:  indices(node.ticketsRevoked),
+		})
+	}
+	cp.ImmatureTickets = indices(s.immatureTickets)
+	for height, tickets := range s.expireHeights {
+		cp.ExpireHeights[height] = indices(tickets)
+	}
+	cp.ExpiredTickets = indices(s.expiredTickets)
+	cp.MissedTickets = indices(s.missedTickets)
+	cp.UnrevokedTickets = indices(s.unrevokedTickets)
+	cp.WonTickets = indices(s.wonTickets)
+	for i := range cp.Tickets {
+		key := tickettreap.Key(cp.Tickets[i].Hash)
+		if s.liveTickets.Has(key) {
+			cp.LiveTickets = append(cp.LiveTickets, uint32(i))
+		}
+	}
+
+	if s.agents != nil {
+		for _, agent := range s.agents.agents {
+			cp.Agents = append(cp.Agents, checkpointAgent{
+				Name:      agent.name,
+				Balance:   agent.balance,
+				Allowance: agent.allowance,
+			})
+		}
+	}
+	for _, stats := range s.ownership.owners {
+		cp.Owners = append(cp.Owners, checkpointOwner{
+			Purchased:   stats.purchased,
+			LiveTickets: stats.liveTickets,
+			Invested:    stats.invested,
+			VoteRewards: stats.voteRewards,
+			Returned:    stats.returned,
+			Refunds:     stats.refunds,
+			Locked:      stats.locked,
+			Abandoned:   stats.abandoned,
+		})
+	}
+	for _, sample := range s.ownership.shareHistory {
+		cp.ShareHistory = append(cp.ShareHistory, checkpointShareSample{
+			Height: sample.height,
+			Shares: sample.shares,
+		})
+	}
+	return cp
+}
+
+// restore replaces the state of the passed simulator, which must be newly
+// created, with the state in the checkpoint.  The checkpoint is not modified,
+// so it may be used to restore any number of simulators.
+//
+// Any scripted staking cap changes that occurred prior to the tip are applied
+// again since the staking cap of the simulator is otherwise configured when it
+// is resumed.
+//
+// The schedule of ticket price algorithms that priced the blocks up to the tip
+// is also restored so the algorithm the simulation is resumed with is scheduled
+// after it.  The caller must configure the algorithm to resume with afterwards.
+//
+// The random number generator is restored to the exact state it was in when
+// the checkpoint was created when the simulator uses the same seed as the
+// checkpoint.  Otherwise, it is left seeded with the seed of the simulator so
+// the simulation continues with different randomness.
+func (cp *simCheckpoint) restore(s *simulator) error {
+	if cp.Network != s.params.Name {
+		return fmt.Errorf("checkpoint is for network %q instead of %q",
+			cp.Network, s.params.Name)
+	}
+	for name, value := range paramsSnapshot(s.params) {
+		if cpValue, ok := cp.Params[name]; !ok || cpValue != value {
+			return fmt.Errorf("checkpoint was created with %s %d "+
+				"instead of %d", name, cpValue, value)
+		}
+	}
+	var numAgents int
+	if s.agents != nil {
+		numAgents = len(s.agents.agents)
+	}
+	if len(cp.Agents) != numAgents {
+		return fmt.Errorf("checkpoint has %d stakeholders instead of %d",
+			len(cp.Agents), numAgents)
+	}
+	for i, cpAgent := range cp.Agents {
+		agent := s.agents.agents[i]
+		if cpAgent.Name != agent.name {
+			return fmt.Errorf("checkpoint has stakeholder %q instead "+
+				"of %q", cpAgent.Name, agent.name)
+		}
+		agent.balance = cpAgent.Balance
+		agent.allowance = cpAgent.Allowance
+	}
+	algo, err := findStakeDiffAlgorithm(cp.Algorithm)
+	if err != nil {
+		return fmt.Errorf("checkpoint has an invalid algorithm: %v", err)
+	}
+	s.algoSchedule = algo.activations()
+
+	tickets := make([]*stakeTicket, 0, len(cp.Tickets))
+	for i := range cp.Tickets {
+		t := &cp.Tickets[i]
+		tickets = append(tickets, &stakeTicket{
+			hash:         t.Hash,
+			blockHeight:  t.BlockHeight,
+			price:        t.Price,
+			winHeight:    t.WinHeight,
+			revokeHeight: t.RevokeHeight,
+			owner:        t.Owner,
+		})
+	}
+	var badIndex bool
+	resolve := func(indices []uint32) []*stakeTicket {
+		if len(indices) == 0 {
+			return nil
+		}
+		result := make([]*stakeTicket, 0, len(indices))
+		for _, idx := range indices {
+			if int(idx) >= len(tickets) {
+				badIndex = true
+				continue
+			}
+			result = append(result, tickets[idx])
+		}
+		return result
+	}
+
+	var tip *blockNode
+	for i := range cp.Blocks {
+		b := &cp.Blocks[i]
+		node := newBlockNode(tip, resolve(b.TicketsAdded),
+			resolve(b.TicketsVoted), resolve(b.TicketsRevoked))
+		if tip == nil {
+			node.height = cp.RootHeight
+			s.root = node
+		}
+		node.header = b.Header
+		node.ticketPrice = b.TicketPrice
+		node.regularSubsidy = b.RegularSubsidy
+		node.poolSize = b.PoolSize
+		node.finalState = b.FinalState
+		node.totalSupply = b.TotalSupply
+		node.spendableSupply = b.SpendableSupply
+		node.numVoters = b.NumVoters
+		tip = node
+	}
+	s.tip = tip
+
+	s.immatureTickets = resolve(cp.ImmatureTickets)
+	for height, indices := range cp.ExpireHeights {
+		s.expireHeights[height] = resolve(indices)
+	}
+	s.expiredTickets = resolve(cp.ExpiredTickets)
+	s.missedTickets = resolve(cp.MissedTickets)
+	s.unrevokedTickets = resolve(cp.UnrevokedTickets)
+	s.wonTickets = resolve(cp.WonTickets)
+	for _, ticket := range resolve(cp.LiveTickets) {
+		s.liveTickets = s.liveTickets.Put(tickettreap.Key(ticket.hash),
+			&tickettreap.Value{
+				PurchaseHeight: ticket.blockHeight,
+				PurchasePrice:  int64(ticket.price),
+				Owner:          ticket.owner,
+			})
+	}
+	if badIndex {
+		return fmt.Errorf("checkpoint references tickets that do not exist")
+	}
+
+	s.totalSupply = cp.TotalSupply
+	s.spendableSupply = cp.SpendableSupply
+	for height, amount := range cp.MaturingSupply {
+		s.maturingSupply[height] = amount
+	}
+	s.invalidatedBlocks = cp.InvalidatedBlocks
+	s.invalidatedSubsidy = cp.InvalidatedSubsidy
+	s.demandPerWindow = cp.DemandPerWindow
+	s.demandComputed = cp.DemandComputed
+	switch m := s.voteModel.(type) {
+	case *fixedMissVoteModel:
+		m.pendingFraction = cp.PendingMisses
+	case *outageVoteModel:
+		m.outageEnd = cp.OutageEnd
+	}
+
+	for i := range cp.Owners {
+		o := &cp.Owners[i]
+		s.ownership.owners = append(s.ownership.owners, ownerStats{
+			purchased:   o.Purchased,
+			liveTickets: o.LiveTickets,
+			invested:    o.Invested,
+			voteRewards: o.VoteRewards,
+			returned:    o.Returned,
+			refunds:     o.Refunds,
+			locked:      o.Locked,
+			abandoned:   o.Abandoned,
+		})
+	}
+	for height, credits := range cp.OwnerMaturing {
+		for owner, amount := range credits {
+			s.ownership.addMaturing(owner, height, amount)
+		}
+	}
+	for _, sample := range cp.ShareHistory {
+		s.ownership.shareHistory = append(s.ownership.shareHistory,
+			poolShareSample{
+				height: sample.Height,
+				shares: append([]float64(nil), sample.Shares...),
+			})
+	}
+
+	if s.tip != nil {
+		for i := range s.events.events {
+			e := &s.events.events[i]
+			if e.Type == "stakingcap" && e.Height <= s.tip.height {
+				s.stakingCap = e.Value
+			}
+		}
+	}
+
+	if s.seed == cp.Seed {
+		s.rngSource.skip(cp.RNGDraws)
+	}
+	return nil
+}
+
+// writeCheckpoint writes the entire state of the simulator as of its tip to a
+// compressed checkpoint file at the provided path.
+func (s *simulator) writeCheckpoint(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	if err := gob.NewEncoder(zw).Encode(s.checkpoint()); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// loadCheckpoint loads a checkpoint from the compressed checkpoint file at the
+// provided path.
+func loadCheckpoint(path string) (*simCheckpoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read checkpoint %q: %v", path,
+			err)
+	}
+	var cp simCheckpoint
+	if err := gob.NewDecoder(zr).Decode(&cp); err != nil {
+		return nil, fmt.Errorf("unable to read checkpoint %q: %v", path,
+			err)
+	}
+	if cp.Version != checkpointVersion {
+		return nil, fmt.Errorf("checkpoint %q has unsupported version %d",
+			path, cp.Version)
+	}
+	return &cp, nil
+}
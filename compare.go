@@ -0,0 +1,238 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/decred/dcrutil"
+)
+
+// comparisonRun houses a completed simulation run using one of the stake
+// difficulty algorithms being compared.
+type comparisonRun struct {
+	algo    *stakeDiffAlgorithm
+	sim     *simulator
+	summary *resultsSummary
+}
+
+// parseAlgorithmList parses the passed comma-separated list of stake difficulty
+// algorithm names and returns the associated algorithms.  At least two
+// distinct algorithms must be specified.
+func parseAlgorithmList(spec string) ([]*stakeDiffAlgorithm, error) {
+	var algos []*stakeDiffAlgorithm
+	seen := make(map[string]struct{})
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		algo, err := findStakeDiffAlgorithm(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[algo.name]; ok {
+			return nil, fmt.Errorf("algorithm %q is specified more "+
+				"than once", algo.name)
+		}
+		seen[algo.name] = struct{}{}
+		algos = append(algos, algo)
+	}
+	if len(algos) < 2 {
+		return nil, fmt.Errorf("at least two algorithms are required " +
+			"for a comparison")
+	}
+	return algos, nil
+}
+
+// comparisonMetric is a single row of the metrics table in the comparison
+// results which holds the value of the metric for each algorithm.
+type comparisonMetric struct {
+	Name   string
+	Values []string
+}
+
+// comparisonMetrics returns the metrics shown for each of the passed runs in
+// the comparison results.
+func comparisonMetrics(runs []comparisonRun) []comparisonMetric {
+	metric := func(name string, value func(*resultsSummary) string) comparisonMetric {
+		values := make([]string, 0, len(runs))
+		for _, run := range runs {
+			values = append(values, value(run.summary))
+		}
+		return comparisonMetric{Name: name, Values: values}
+	}
+	amount := func(amount dcrutil.Amount) string {
+		return amount.String()
+	}
+	count := func(count int) string {
+		return strconv.Itoa(count)
+	}
+	metrics := []comparisonMetric{
+		metric("Min Ticket Price", func(s *resultsSummary) string {
+			return amount(s.MinTicketPrice)
+		}),
+		metric("Max Ticket Price", func(s *resultsSummary) string {
+			return amount(s.MaxTicketPrice)
+		}),
+		metric("Min Pool Size", func(s *resultsSummary) string {
+			return count(int(s.MinPoolSize))
+		}),
+		metric("Max Pool Size", func(s *resultsSummary) string {
+			return count(int(s.MaxPoolSize))
+		}),
+		metric("Total Tickets", func(s *resultsSummary) string {
+			return count(s.NumTickets)
+		}),
+		metric("Total Winning Tickets", func(s *resultsSummary) string {
+			return count(s.NumWinners)
+		}),
+		metric("Total Expired Tickets", func(s *resultsSummary) string {
+			return count(s.NumExpired)
+		}),
+		metric("Total Missed Tickets", func(s *resultsSummary) string {
+			return count(s.NumMissed)
+		}),
+		metric("Total Coin Supply", func(s *resultsSummary) string {
+			return amount(s.CoinSupply)
+		}),
+		metric("Spendable Coin Supply", func(s *resultsSummary) string {
+			return amount(s.SpendableSupply)
+		}),
+	}
+
+	// Add the stability metrics of each run in the same order they are
+	// shown in the results of a single simulation.
+	stability := make([][]formattedMetric, 0, len(runs))
+	for _, run := range runs {
+		stability = append(stability, run.summary.Stability.formatted())
+	}
+	for i := range stability[0] {
+		values := make([]string, 0, len(runs))
+		for _, formatted := range stability {
+			values = append(values, formatted[i].Value)
+		}
+		metrics = append(metrics, comparisonMetric{
+			Name:   stability[0][i].Name,
+			Values: values,
+		})
+	}
+	return metrics
+}
+
+// comparisonCSV returns CSV data with a column for each of the passed runs that
+// contains the value returned by the provided function for every block for
+// which the filter function returns true.  Blocks that only exist in some of
+// the runs are left empty in the others.
+func comparisonCSV(runs []comparisonRun, filter func(*blockNode) bool, value func(*blockNode) string) string {
+	nodes := make([]*blockNode, len(runs))
+	for i, run := range runs {
+		nodes[i] = run.sim.root
+	}
+
+	var csv bytes.Buffer
+	for {
+		var height int32 = -1
+		for _, node := range nodes {
+			if node != nil {
+				height = node.height
+				break
+			}
+		}
+		if height == -1 {
+			break
+		}
+
+		include := false
+		for _, node := range nodes {
+			if node != nil && filter(node) {
+				include = true
+				break
+			}
+		}
+		if include {
+			csv.WriteString(strconv.Itoa(int(height)))
+			for _, node := range nodes {
+				csv.WriteRune(',')
+				if node != nil {
+					csv.WriteString(value(node))
+				}
+			}
+			csv.WriteRune('\n')
+		}
+
+		for i, node := range nodes {
+			if node != nil {
+				nodes[i] = node.next
+			}
+		}
+	}
+	return csv.String()
+}
+
+// generateComparisonResults creates an HTML results file at the provided path
+// that compares the passed simulation runs with overlaid charts and a table of
+// metrics for each algorithm.
+func generateComparisonResults(runs []comparisonRun, resultsPath string) error {
+	// Parse the comparison template along with the results template which
+	// defines the shared portions.
+	tpl, err := template.New("results").Parse(resultsTmplText)
+	if err != nil {
+		return fmt.Errorf("unable to parse results template: %v", err)
+	}
+	tpl, err = tpl.New("compare").Parse(compareTmplText)
+	if err != nil {
+		return fmt.Errorf("unable to parse comparison template: %v", err)
+	}
+	resultsFile, err := os.Create(resultsPath)
+	if err != nil {
+		return fmt.Errorf("unable to create results: %v", err)
+	}
+	defer resultsFile.Close()
+
+	// Label each run with the full schedule of algorithms it used, which
+	// differs from the compared algorithm when it continues from replayed
+	// data or a checkpoint, and mark the heights at which they activated.
+	algoNames := make([]string, 0, len(runs))
+	activations := make([][]int32, 0, len(runs))
+	for _, run := range runs {
+		algoNames = append(algoNames, run.sim.algoName)
+		activations = append(activations,
+			run.sim.algoSchedule.activationHeights())
+	}
+	labels := append([]string{"Block"}, algoNames...)
+	windowSize := int32(runs[0].sim.params.StakeDiffWindowSize)
+	poolSizeCSV := comparisonCSV(runs, func(*blockNode) bool {
+		return true
+	}, func(node *blockNode) string {
+		return strconv.FormatUint(uint64(node.poolSize), 10)
+	})
+	ticketPriceCSV := comparisonCSV(runs, func(node *blockNode) bool {
+		return node.height%windowSize == 0
+	}, func(node *blockNode) string {
+		price := dcrutil.Amount(node.ticketPrice).ToCoin()
+		return strconv.FormatFloat(price, 'f', 8, 64)
+	})
+
+	err = tpl.Execute(resultsFile, map[string]interface{}{
+		"Algorithms":     algoNames,
+		"Labels":         labels,
+		"Activations":    activations,
+		"Seed":           strconv.FormatInt(runs[0].summary.Seed, 10),
+		"Metrics":        comparisonMetrics(runs),
+		"PoolSizeCSV":    poolSizeCSV,
+		"TicketPriceCSV": ticketPriceCSV,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to execute template: %v", err)
+	}
+
+	return resultsFile.Close()
+}
@@ -0,0 +1,94 @@
+package main
+
+var compareTmplText = `
+<!doctype html>
+<html lang="en">
+  <head>
+    <meta charset="utf-8">
+    <meta http-equiv="X-UA-Compatible" content="IE=edge">
+    <title>Simulation Comparison Results</title>
+    {{template "libs"}}
+  </head>
+  <body style="margin: 0px; padding: 0px;">
+    <div id="container" style="width: 100%;">
+      {{template "navbar"}}
+      <div style="width: 95%;">
+        <table>
+          <tr>
+            <th>Random Seed</th>
+            <th colspan="{{len .Algorithms}}">{{.Seed}}</th>
+          </tr>
+          <tr>
+            <th>Metric</th>
+            {{range .Algorithms}}
+            <th>{{.}}</th>
+            {{end}}
+          </tr>
+          {{range .Metrics}}
+          <tr>
+            <td>{{.Name}}</td>
+            {{range .Values}}
+            <td>{{.}}</td>
+            {{end}}
+          </tr>
+          {{end}}
+        </table>
+      </div>
+      <div id="charts" style="width: 95%; text-align: center;">
+        <div id="poolsizediv" style="width: 50%; float: left;"></div>
+        <div id="ticketpricediv" style="width: 50%; float: right;"></div>
+      </div>
+    </div>
+
+    <script>
+      // Mark the heights at which the stake difficulty algorithm of each
+      // run changes in the color of the run.
+      var activations = {{.Activations}};
+      var markActivations = function(canvas, area, g) {
+        var colors = g.getColors();
+        activations.forEach(function(heights, i) {
+          canvas.fillStyle = colors[i];
+          heights.forEach(function(height) {
+            canvas.fillRect(g.toDomXCoord(height), area.y, 2, area.h);
+          });
+        });
+      };
+
+      window.onload = function() {
+        var csv = "{{.PoolSizeCSV}}";
+        var poolSizeGraph = new Dygraph(document.getElementById("poolsizediv"), csv,
+          {
+            title: 'Pool Size Per Block',
+            labels: {{.Labels}},
+            xlabel: 'Block Height',
+            ylabel: 'Pool Size',
+            legend: 'always',
+            underlayCallback: markActivations,
+            animatedZooms: true,
+            plugins : [
+                Dygraph.Plugins.Unzoom
+            ]
+          }
+        );
+
+        var csv = "{{.TicketPriceCSV}}";
+        var ticketPriceGraph = new Dygraph(document.getElementById("ticketpricediv"), csv,
+          {
+            title: 'Ticket Price Per Retarget Interval',
+            labels: {{.Labels}},
+            xlabel: 'Block Height',
+            ylabel: 'Ticket Price',
+            legend: 'always',
+            drawPoints: true,
+            underlayCallback: markActivations,
+            animatedZooms: true,
+            plugins : [
+                Dygraph.Plugins.Unzoom
+            ]
+          }
+        );
+      }
+    </script>
+  </body>
+</html>
+`
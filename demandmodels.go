@@ -0,0 +1,438 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/decred/dcrutil"
+)
+
+// demandModel describes a model of ticket purchasing behavior.
+type demandModel interface {
+	// demand returns a simulated demand (as a percentage of the number of
+	// tickets to purchase within a given stake difficulty interval) for the
+	// provided ticket price in the block at the given height.
+	demand(s *simulator, nextHeight int32, ticketPrice int64) float64
+}
+
+// demandParam describes a tunable parameter of a demand model.
+type demandParam struct {
+	name         string
+	defaultValue float64
+	description  string
+}
+
+// demandModelInfo describes a named demand model that the full simulation is
+// able to use to make ticket purchase decisions.
+type demandModelInfo struct {
+	// name is the unique name used to select the model.
+	name string
+
+	// description is a short human-readable description of the model.
+	description string
+
+	// params are the tunable parameters the model accepts along with their
+	// default values.
+	params []demandParam
+
+	// newModel returns a new instance of the model configured with the
+	// passed parameters, which always contains a value for every parameter
+	// defined by the model.
+	newModel func(params map[string]float64) (demandModel, error)
+}
+
+// yieldVWAPParams are the tunable parameters of the yield and VWAP based
+// demand model.  They are shared with the noisy model which is based on it.
+var yieldVWAPParams = []demandParam{
+	{"minyield", 0.02, "Yield at or below which there is no demand"},
+	{"maxyield", 0.05, "Yield at or above which there is full demand"},
+	{"minvwap", 0.8, "Fraction of the VWAP at or below which there is " +
+		"full demand"},
+	{"maxvwap", 1.2, "Fraction of the VWAP at or above which there is no " +
+		"demand"},
+}
+
+// demandModels houses all of the demand models that are available to the full
+// simulation.
+//
+// NOTE: New models should be added here in order to make them selectable via
+// the -demand flag.
+var demandModels = []demandModelInfo{
+	{
+		name: "yieldvwap",
+		description: "Product of a linear demand based on yield and a " +
+			"linear demand based on the VWAP",
+		params:   yieldVWAPParams,
+		newModel: newYieldVWAPDemandModel,
+	},
+	{
+		name:        "constant",
+		description: "Constant demand regardless of price",
+		params: []demandParam{
+			{"level", 0.5, "Fraction of the max tickets per window to " +
+				"purchase"},
+		},
+		newModel: newConstantDemandModel,
+	},
+	{
+		name: "elastic",
+		description: "Price-elastic demand relative to the price that " +
+			"produces a target yield",
+		params: []demandParam{
+			{"targetyield", 0.035, "Yield at which the demand is the " +
+				"reference level"},
+			{"level", 0.5, "Demand at the price that produces the " +
+				"target yield"},
+			{"elasticity", 2, "Price elasticity of demand"},
+		},
+		newModel: newElasticDemandModel,
+	},
+	{
+		name: "noisy",
+		description: "The yieldvwap model with normally distributed " +
+			"random noise added",
+		params: append(append([]demandParam(nil), yieldVWAPParams...),
+			demandParam{"stddev", 0.1, "Standard deviation of the " +
+				"noise"}),
+		newModel: newNoisyDemandModel,
+	},
+}
+
+// defaultDemandModel is the name of the demand model to use when none is
+// specified.
+const defaultDemandModel = "yieldvwap"
+
+// findDemandModel returns the registered demand model with the provided name.
+// An error is returned when there is no such model.
+func findDemandModel(name string) (*demandModelInfo, error) {
+	for i := range demandModels {
+		info := &demandModels[i]
+		if strings.EqualFold(info.name, name) {
+			return info, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown demand model %q -- use -listdemand to "+
+		"show the available models", name)
+}
+
+// listDemandModels writes the names, descriptions, and parameters of all
+// registered demand models to the passed writer.
+func listDemandModels(w io.Writer) {
+	fmt.Fprintln(w, "Available demand models:")
+	for _, info := range demandModels {
+		defaultStr := ""
+		if info.name == defaultDemandModel {
+			defaultStr = " (default)"
+		}
+		fmt.Fprintf(w, "  %-10s %s%s\n", info.name, info.description,
+			defaultStr)
+		for _, param := range info.params {
+			fmt.Fprintf(w, "    %-12s %s (default %v)\n", param.name,
+				param.description, param.defaultValue)
+		}
+	}
+}
+
+// resolveParams returns the full set of parameters for the demand model with
+// the passed overrides applied.  Any parameters that are not overridden use the
+// defaults defined by the model, while any overrides the model does not define
+// result in an error.
+func (info *demandModelInfo) resolveParams(overrides map[string]float64) (map[string]float64, error) {
+	params := make(map[string]float64, len(info.params))
+	for _, param := range info.params {
+		params[param.name] = param.defaultValue
+	}
+	for name, value := range overrides {
+		if _, ok := params[name]; !ok {
+			return nil, fmt.Errorf("demand model %q does not have a "+
+				"parameter named %q", info.name, name)
+		}
+		params[name] = value
+	}
+	return params, nil
+}
+
+// newDemandModel returns a new instance of the demand model configured with the
+// passed parameter overrides.  See resolveParams for details.
+func (info *demandModelInfo) newDemandModel(overrides map[string]float64) (demandModel, error) {
+	params, err := info.resolveParams(overrides)
+	if err != nil {
+		return nil, err
+	}
+	return info.newModel(params)
+}
+
+// parseDemandParams parses the passed comma-separated list of name=value pairs
+// into a map of demand model parameters.
+func parseDemandParams(spec string) (map[string]float64, error) {
+	params := make(map[string]float64)
+	if strings.TrimSpace(spec) == "" {
+		return params, nil
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("malformed demand parameter %q -- "+
+				"expected name=value", pair)
+		}
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		value, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed value for demand "+
+				"parameter %q: %v", name, err)
+		}
+		params[name] = value
+	}
+	return params, nil
+}
+
+// formatDemandParams returns the passed demand model parameters as a sorted
+// comma-separated list of name=value pairs.
+func formatDemandParams(params map[string]float64) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", name, params[name]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// perVoteSubsidy returns the subsidy each vote in the block at the given height
+// receives.
+func (s *simulator) perVoteSubsidy(nextHeight int32) int64 {
+	ticketsPerBlock := s.params.TicketsPerBlock
+	posSubsidy := s.calcPoSSubsidy(nextHeight - 1)
+	return int64(posSubsidy / dcrutil.Amount(ticketsPerBlock))
+}
+
+// clampDemand limits the passed demand to the range [0, 1].
+func clampDemand(demand float64) float64 {
+	if demand < 0 || math.IsNaN(demand) {
+		return 0
+	}
+	if demand > 1 {
+		return 1
+	}
+	return demand
+}
+
+// calcYieldDemand returns a simulated demand (as a percentage of the number of
+// tickets to purchase within a given stake difficulty interval) based upon the
+// estimated yield purchasing a ticket would produce.
+func calcYieldDemand(ticketPrice, perVoteSubsidy int64, minYield, maxYield float64) float64 {
+	// 100% demand when the yield is over the max yield.
+	yield := float64(perVoteSubsidy) / float64(ticketPrice)
+	if yield > maxYield {
+		return 1.0
+	}
+
+	// No demand when the yield is under the min yield.
+	if yield < minYield {
+		return 0.0
+	}
+
+	// The yield is in between the min and max yield, so create a linear
+	// demand accordingly.
+	return (yield - minYield) / (maxYield - minYield)
+}
+
+// calcVWAPDemand returns a simulated demand (as a percentage of the number of
+// tickets to purchase within a given stake difficulty interval) based upon the
+// volume-weighted average ticket purchase of the previous ticket price windows.
+func calcVWAPDemand(ticketPrice, ticketVWAP int64, minVWAP, maxVWAP float64) float64 {
+	// 100% demand when there is no VWAP since there were no purchases.
+	if ticketVWAP <= 0 {
+		return 1.0
+	}
+
+	// 100% demand when the ticket price is under the min fraction of the
+	// VWAP.
+	lowVWAP := int64(float64(ticketVWAP) * minVWAP)
+	if ticketPrice < lowVWAP {
+		return 1.0
+	}
+
+	// No demand when the ticket price is over the max fraction of the VWAP.
+	highVWAP := int64(float64(ticketVWAP) * maxVWAP)
+	if ticketPrice > highVWAP {
+		return 0.0
+	}
+
+	// The ticket price is in between the min and max fraction of the VWAP,
+	// so create a linear demand accordingly.
+	return 1 - float64(ticketPrice-lowVWAP)/float64(highVWAP-lowVWAP)
+}
+
+// yieldVWAPDemandModel is a demand model that combines a linear demand based on
+// the yield a ticket would produce with a linear demand based on the ticket
+// price relative to the volume-weighted average price of previous windows.
+type yieldVWAPDemandModel struct {
+	minYield, maxYield float64
+	minVWAP, maxVWAP   float64
+}
+
+// newYieldVWAPDemandModel returns a new yield and VWAP based demand model
+// configured with the passed parameters.
+func newYieldVWAPDemandModel(params map[string]float64) (demandModel, error) {
+	m := &yieldVWAPDemandModel{
+		minYield: params["minyield"],
+		maxYield: params["maxyield"],
+		minVWAP:  params["minvwap"],
+		maxVWAP:  params["maxvwap"],
+	}
+	if m.minYield < 0 || m.minYield >= m.maxYield {
+		return nil, fmt.Errorf("minyield must be non-negative and less " +
+			"than maxyield")
+	}
+	if m.minVWAP < 0 || m.minVWAP >= m.maxVWAP {
+		return nil, fmt.Errorf("minvwap must be non-negative and less " +
+			"than maxvwap")
+	}
+	return m, nil
+}
+
+// demand returns a simulated demand based on the yield and VWAP.
+//
+// This is part of the demandModel interface.
+func (m *yieldVWAPDemandModel) demand(s *simulator, nextHeight int32, ticketPrice int64) float64 {
+	// Calculate the demand based on yield.
+	perVoteSubsidy := s.perVoteSubsidy(nextHeight)
+	yieldDemand := calcYieldDemand(ticketPrice, perVoteSubsidy, m.minYield,
+		m.maxYield)
+
+	// Calculate the demand based on the volume-weighted average ticket
+	// purchase price.
+	currentVWAP := s.calcPrevVWAP(s.tip)
+	vwapDemand := calcVWAPDemand(ticketPrice, currentVWAP, m.minVWAP,
+		m.maxVWAP)
+
+	// The demand is the combination of the two unless there is full demand
+	// based on yield and no demand based on the VWAP, in which case there
+	// is 100% demand.
+	demand := yieldDemand * vwapDemand
+	if yieldDemand == 1.0 && vwapDemand == 0.0 {
+		demand = 1.0
+	}
+	return demand
+}
+
+// constantDemandModel is a demand model with the same demand regardless of the
+// ticket price.
+type constantDemandModel struct {
+	level float64
+}
+
+// newConstantDemandModel returns a new constant demand model configured with
+// the passed parameters.
+func newConstantDemandModel(params map[string]float64) (demandModel, error) {
+	level := params["level"]
+	if err := validateRate("level", level); err != nil {
+		return nil, err
+	}
+	return &constantDemandModel{level: level}, nil
+}
+
+// demand returns the configured constant demand.
+//
+// This is part of the demandModel interface.
+func (m *constantDemandModel) demand(s *simulator, nextHeight int32, ticketPrice int64) float64 {
+	return m.level
+}
+
+// elasticDemandModel is a demand model with a constant price elasticity
+// relative to a reference price, which is the price that produces a target
+// yield.  That is to say the demand is:
+//
+//   level * (referencePrice / ticketPrice)^elasticity
+type elasticDemandModel struct {
+	targetYield float64
+	level       float64
+	elasticity  float64
+}
+
+// newElasticDemandModel returns a new price-elastic demand model configured
+// with the passed parameters.
+func newElasticDemandModel(params map[string]float64) (demandModel, error) {
+	m := &elasticDemandModel{
+		targetYield: params["targetyield"],
+		level:       params["level"],
+		elasticity:  params["elasticity"],
+	}
+	if m.targetYield <= 0 {
+		return nil, fmt.Errorf("targetyield must be greater than zero")
+	}
+	if err := validateRate("level", m.level); err != nil {
+		return nil, err
+	}
+	if m.elasticity < 0 {
+		return nil, fmt.Errorf("elasticity must not be negative")
+	}
+	return m, nil
+}
+
+// demand returns a simulated demand based on the ticket price relative to the
+// price that produces the target yield.
+//
+// This is part of the demandModel interface.
+func (m *elasticDemandModel) demand(s *simulator, nextHeight int32, ticketPrice int64) float64 {
+	referencePrice := float64(s.perVoteSubsidy(nextHeight)) / m.targetYield
+	ratio := referencePrice / float64(ticketPrice)
+	return clampDemand(m.level * math.Pow(ratio, m.elasticity))
+}
+
+// noisyDemandModel is a demand model that adds normally distributed random
+// noise to the yield and VWAP based demand model.
+type noisyDemandModel struct {
+	base   demandModel
+	stddev float64
+}
+
+// newNoisyDemandModel returns a new noisy demand model configured with the
+// passed parameters.
+func newNoisyDemandModel(params map[string]float64) (demandModel, error) {
+	base, err := newYieldVWAPDemandModel(params)
+	if err != nil {
+		return nil, err
+	}
+	stddev := params["stddev"]
+	if stddev < 0 {
+		return nil, fmt.Errorf("stddev must not be negative")
+	}
+	return &noisyDemandModel{base: base, stddev: stddev}, nil
+}
+
+// demand returns a simulated demand based on the yield and VWAP with random
+// noise added.
+//
+// This is part of the demandModel interface.
+func (m *noisyDemandModel) demand(s *simulator, nextHeight int32, ticketPrice int64) float64 {
+	demand := m.base.demand(s, nextHeight, ticketPrice)
+	return clampDemand(demand + s.rng.NormFloat64()*m.stddev)
+}
+
+// newDefaultDemandModel returns a new instance of the default demand model
+// configured with its default parameters.
+func newDefaultDemandModel() demandModel {
+	info, err := findDemandModel(defaultDemandModel)
+	if err != nil {
+		panic(err)
+	}
+	model, err := info.newDemandModel(nil)
+	if err != nil {
+		panic(err)
+	}
+	return model
+}
@@ -0,0 +1,258 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+// eventConfig houses the configuration of an event that is scripted to occur
+// at a given height during a full simulation.
+type eventConfig struct {
+	// Height is the height of the first block the event applies to.
+	Height int32 `json:"height"`
+
+	// Type is the type of the event.  It must be one of demand, whale,
+	// missvotes, or stakingcap.
+	Type string `json:"type"`
+
+	// Duration is the number of blocks the event lasts.  When it is zero,
+	// whale events last for a single ticket price window and demand and
+	// missvotes events last until the end of the simulation.  It does not
+	// apply to stakingcap events since they are permanent.
+	Duration int32 `json:"duration"`
+
+	// Value is the value associated with the event.  For demand events it
+	// is the factor the number of tickets purchased is multiplied by, so
+	// values greater than one are spikes and values less than one are
+	// drops.  For missvotes events it is the probability each vote is
+	// missed in addition to the misses of the vote model.  For stakingcap
+	// events it is the new fraction of the total supply that may be
+	// staked.  It does not apply to whale events.
+	Value float64 `json:"value"`
+}
+
+// validate returns an error if the event is not valid.
+func (e *eventConfig) validate() error {
+	if e.Height < 0 {
+		return fmt.Errorf("height of %s event must not be negative -- "+
+			"got %d", e.Type, e.Height)
+	}
+	if e.Duration < 0 {
+		return fmt.Errorf("duration of %s event at height %d must not "+
+			"be negative -- got %d", e.Type, e.Height, e.Duration)
+	}
+	switch e.Type {
+	case "demand":
+		if e.Value < 0 {
+			return fmt.Errorf("value of demand event at height %d "+
+				"must not be negative -- got %v", e.Height,
+				e.Value)
+		}
+	case "whale":
+	case "missvotes", "stakingcap":
+		name := fmt.Sprintf("value of %s event at height %d", e.Type,
+			e.Height)
+		if err := validateRate(name, e.Value); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("event at height %d has unknown type %q -- "+
+			"must be demand, whale, missvotes, or stakingcap",
+			e.Height, e.Type)
+	}
+	return nil
+}
+
+// active returns whether or not the event applies to the block at the passed
+// height.
+func (e *eventConfig) active(height int32, windowSize int32) bool {
+	if height < e.Height {
+		return false
+	}
+	duration := e.Duration
+	if duration == 0 {
+		if e.Type != "whale" {
+			return true
+		}
+		duration = windowSize
+	}
+	return height < e.Height+duration
+}
+
+// eventsByHeight implements sort.Interface to allow a slice of events to be
+// sorted by their height.
+type eventsByHeight []eventConfig
+
+// Len returns the number of events in the slice.  It is part of the
+// sort.Interface implementation.
+func (s eventsByHeight) Len() int {
+	return len(s)
+}
+
+// Swap swaps the events at the passed indices.  It is part of the
+// sort.Interface implementation.
+func (s eventsByHeight) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// Less returns whether the event with index i should sort before the event
+// with index j.  It is part of the sort.Interface implementation.
+func (s eventsByHeight) Less(i, j int) bool {
+	return s[i].Height < s[j].Height
+}
+
+// eventSchedule houses the events scripted to occur during a full simulation
+// sorted by height.
+type eventSchedule struct {
+	events []eventConfig
+}
+
+// newEventSchedule returns a new event schedule for the passed events after
+// ensuring they are all valid.
+func newEventSchedule(cfgs []eventConfig) (*eventSchedule, error) {
+	events := make([]eventConfig, len(cfgs))
+	copy(events, cfgs)
+	for i := range events {
+		if err := events[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	sort.Stable(eventsByHeight(events))
+	return &eventSchedule{events: events}, nil
+}
+
+// demandFactor returns the factor the number of tickets purchased in the block
+// at the passed height is multiplied by according to the active demand events.
+func (sch *eventSchedule) demandFactor(height int32, windowSize int32) float64 {
+	factor := 1.0
+	for i := range sch.events {
+		e := &sch.events[i]
+		if e.Type == "demand" && e.active(height, windowSize) {
+			factor *= e.Value
+		}
+	}
+	return factor
+}
+
+// whaleActive returns whether or not a whale event applies to the block at the
+// passed height.
+func (sch *eventSchedule) whaleActive(height int32, windowSize int32) bool {
+	for i := range sch.events {
+		e := &sch.events[i]
+		if e.Type == "whale" && e.active(height, windowSize) {
+			return true
+		}
+	}
+	return false
+}
+
+// missRate returns the probability each vote in the block at the passed height
+// is missed according to the active missvotes events.
+func (sch *eventSchedule) missRate(height int32, windowSize int32) float64 {
+	voteRate := 1.0
+	for i := range sch.events {
+		e := &sch.events[i]
+		if e.Type == "missvotes" && e.active(height, windowSize) {
+			voteRate *= 1 - e.Value
+		}
+	}
+	return 1 - voteRate
+}
+
+// stakingCap returns the staking cap that applies starting at the passed height
+// along with whether or not it is changed by a stakingcap event at that height.
+// When there are multiple such events, the last one specified wins.
+func (sch *eventSchedule) stakingCap(height int32) (float64, bool) {
+	var stakingCap float64
+	var changed bool
+	for i := range sch.events {
+		e := &sch.events[i]
+		if e.Type == "stakingcap" && e.Height == height {
+			stakingCap, changed = e.Value, true
+		}
+	}
+	return stakingCap, changed
+}
+
+// shockHeights returns the unique heights at which the events start in
+// ascending order.
+func (sch *eventSchedule) shockHeights() []int32 {
+	var heights []int32
+	for i := range sch.events {
+		height := sch.events[i].Height
+		if len(heights) > 0 && heights[len(heights)-1] == height {
+			continue
+		}
+		heights = append(heights, height)
+	}
+	return heights
+}
+
+// loadEventConfigs loads the scripted events from the JSON file at the provided
+// path.  The file must contain an array of events.
+func loadEventConfigs(path string) ([]eventConfig, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfgs []eventConfig
+	if err := json.Unmarshal(contents, &cfgs); err != nil {
+		return nil, fmt.Errorf("unable to parse events file %q: %v",
+			path, err)
+	}
+	if _, err := newEventSchedule(cfgs); err != nil {
+		return nil, err
+	}
+	return cfgs, nil
+}
+
+// eventDemandFactor returns the factor the number of tickets purchased in the
+// block at the passed height is multiplied by according to the scripted demand
+// events.
+func (s *simulator) eventDemandFactor(height int32) float64 {
+	windowSize := int32(s.params.StakeDiffWindowSize)
+	return s.events.demandFactor(height, windowSize)
+}
+
+// eventWhaleTickets returns the number of tickets purchased by a scripted whale
+// in the block at the passed height in addition to the passed number of tickets
+// already being purchased.  The whale purchases all of the remaining tickets
+// allowed in the block that can be afforded while it is active.
+func (s *simulator) eventWhaleTickets(height int32, newTickets uint8) uint8 {
+	windowSize := int32(s.params.StakeDiffWindowSize)
+	if !s.events.whaleActive(height, windowSize) {
+		return 0
+	}
+	ticketPrice := s.nextTicketPriceFunc()
+	if ticketPrice <= 0 {
+		return 0
+	}
+	whaleTickets := int64(s.params.MaxFreshStakePerBlock) - int64(newTickets)
+	affordable := int64(s.spendableSupply)/ticketPrice - int64(newTickets)
+	if whaleTickets > affordable {
+		whaleTickets = affordable
+	}
+	if whaleTickets <= 0 {
+		return 0
+	}
+	return uint8(whaleTickets)
+}
+
+// eventVotes returns the passed number of votes after randomly removing the
+// additional misses caused by the scripted missvotes events that are active at
+// the passed height.
+func (s *simulator) eventVotes(height int32, numVotes uint16) uint16 {
+	windowSize := int32(s.params.StakeDiffWindowSize)
+	missRate := s.events.missRate(height, windowSize)
+	if numVotes == 0 || missRate == 0 {
+		return numVotes
+	}
+	misses := randomMisses(numVotes, missRate, s.rng)
+	return clampVotes(int(numVotes)-misses, s.params.TicketsPerBlock)
+}
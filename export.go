@@ -0,0 +1,189 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/decred/dcrutil"
+)
+
+const (
+	// blocksCSVFileName is the name of the file the per-block time series
+	// is written to in the output directory.
+	blocksCSVFileName = "blocks.csv"
+
+	// summaryJSONFileName is the name of the file the summary of the
+	// headline stats is written to in the output directory.
+	summaryJSONFileName = "summary.json"
+)
+
+// resultsSummary houses the headline stats of a completed simulation.  All
+// amounts are in atoms.
+type resultsSummary struct {
+	Network            string           `json:"network"`
+	Algorithm          string           `json:"algorithm"`
+	Seed               int64            `json:"seed"`
+	Height             int32            `json:"height"`
+	MinTicketPrice     dcrutil.Amount   `json:"minticketprice"`
+	MaxTicketPrice     dcrutil.Amount   `json:"maxticketprice"`
+	NumTickets         int              `json:"numtickets"`
+	NumWinners         int              `json:"numwinners"`
+	NumExpired         int              `json:"numexpired"`
+	NumMissed          int              `json:"nummissed"`
+	NumUnrevoked       int              `json:"numunrevoked"`
+	UnrevokedAmount    dcrutil.Amount   `json:"unrevokedamount"`
+	MinPoolSize        uint32           `json:"minpoolsize"`
+	MaxPoolSize        uint32           `json:"maxpoolsize"`
+	NumInvalidated     uint32           `json:"numinvalidated"`
+	InvalidatedSubsidy dcrutil.Amount   `json:"invalidatedsubsidy"`
+	CoinSupply         dcrutil.Amount   `json:"coinsupply"`
+	SpendableSupply    dcrutil.Amount   `json:"spendablesupply"`
+	Stability          stabilityMetrics `json:"stability"`
+	Owners             []ownerSummary   `json:"owners"`
+}
+
+// summarizeResults returns the headline stats of the simulation.
+func (s *simulator) summarizeResults() *resultsSummary {
+	// Shorter version of some params for convenience.
+	stakeValidationHeight := int32(s.params.StakeValidationHeight)
+
+	minTicketPrice, maxTicketPrice := int64(math.MaxInt64), int64(0)
+	minPoolSize, maxPoolSize := uint32(math.MaxUint32), uint32(0)
+	for node := s.root; node != nil; node = node.next {
+		if node.ticketPrice < minTicketPrice {
+			minTicketPrice = node.ticketPrice
+		}
+		if node.ticketPrice > maxTicketPrice {
+			maxTicketPrice = node.ticketPrice
+		}
+
+		// Only consider pool size after stake validation height unless
+		// the entire simulation is before that point.
+		if node.height >= stakeValidationHeight || s.tip.height < stakeValidationHeight {
+			if node.poolSize < minPoolSize {
+				minPoolSize = node.poolSize
+			}
+			if node.poolSize > maxPoolSize {
+				maxPoolSize = node.poolSize
+			}
+		}
+	}
+
+	var unrevokedAmount dcrutil.Amount
+	for _, ticket := range s.unrevokedTickets {
+		unrevokedAmount += ticket.price
+	}
+	var height int32
+	if s.tip != nil {
+		height = s.tip.height
+	}
+	numTickets := s.liveTickets.Len() + len(s.wonTickets) +
+		len(s.expiredTickets)
+	return &resultsSummary{
+		Network:            s.params.Name,
+		Algorithm:          s.algoName,
+		Seed:               s.seed,
+		Height:             height,
+		MinTicketPrice:     dcrutil.Amount(minTicketPrice),
+		MaxTicketPrice:     dcrutil.Amount(maxTicketPrice),
+		NumTickets:         numTickets,
+		NumWinners:         len(s.wonTickets),
+		NumExpired:         len(s.expiredTickets),
+		NumMissed:          len(s.missedTickets),
+		NumUnrevoked:       len(s.unrevokedTickets),
+		UnrevokedAmount:    unrevokedAmount,
+		MinPoolSize:        minPoolSize,
+		MaxPoolSize:        maxPoolSize,
+		NumInvalidated:     s.invalidatedBlocks,
+		InvalidatedSubsidy: s.invalidatedSubsidy,
+		CoinSupply:         s.totalSupply,
+		SpendableSupply:    s.spendableSupply,
+		Stability:          s.calcStabilityMetrics(),
+		Owners:             s.ownerSummaries(),
+	}
+}
+
+// writeBlocksCSV writes the per-block time series of the simulation to the
+// file at the provided path.  Amounts are in atoms so they can be consumed
+// without any loss of precision.
+func (s *simulator) writeBlocksCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	err = w.Write([]string{"height", "ticketprice", "poolsize",
+		"totalsupply", "spendablesupply", "votes", "purchases",
+		"revocations"})
+	if err != nil {
+		return err
+	}
+	for node := s.root; node != nil; node = node.next {
+		err := w.Write([]string{
+			strconv.Itoa(int(node.height)),
+			strconv.FormatInt(node.ticketPrice, 10),
+			strconv.FormatUint(uint64(node.poolSize), 10),
+			strconv.FormatInt(int64(node.totalSupply), 10),
+			strconv.FormatInt(int64(node.spendableSupply), 10),
+			strconv.FormatUint(uint64(node.numVoters), 10),
+			strconv.Itoa(len(node.ticketsAdded)),
+			strconv.Itoa(len(node.ticketsRevoked)),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// writeSummaryJSON writes the passed summary of the simulation as JSON to the
+// file at the provided path.
+func writeSummaryJSON(path string, summary *resultsSummary) error {
+	contents, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	contents = append(contents, '\n')
+	return ioutil.WriteFile(path, contents, 0644)
+}
+
+// exportResults writes the per-block time series and a summary of the headline
+// stats of the simulation as machine-readable files in the provided directory.
+// The directory is created if it does not already exist.
+func (s *simulator) exportResults(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("unable to create output directory: %v", err)
+	}
+	blocksPath := filepath.Join(dir, blocksCSVFileName)
+	if err := s.writeBlocksCSV(blocksPath); err != nil {
+		return fmt.Errorf("unable to write %q: %v", blocksPath, err)
+	}
+	summaryPath := filepath.Join(dir, summaryJSONFileName)
+	err := writeSummaryJSON(summaryPath, s.summarizeResults())
+	if err != nil {
+		return fmt.Errorf("unable to write %q: %v", summaryPath, err)
+	}
+	if s.validator != nil {
+		path := filepath.Join(dir, validationCSVFileName)
+		if err := s.validator.writeCSV(path); err != nil {
+			return fmt.Errorf("unable to write %q: %v", path, err)
+		}
+	}
+	return nil
+}
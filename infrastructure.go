@@ -35,10 +35,6 @@ var (
 		0xd3}
 )
 
-func init() {
-	rand.Seed(time.Now().Unix())
-}
-
 // openBrowser tries to open the provided URL in a browser and reports whether
 // or not it succeeded.
 func openBrowser(url string) bool {
@@ -122,6 +118,21 @@ func (hp *hash256prng) Hash256Rand() uint32 {
 	return r
 }
 
+// stateHash returns a hash referencing the current state of the deterministic
+// PRNG.
+func (hp *hash256prng) stateHash() chainhash.Hash {
+	fHash := hp.cachedHash
+	fIdx := hp.idx
+	fHashOffset := hp.hashOffset
+
+	finalState := make([]byte, len(fHash)+4+1)
+	copy(finalState, fHash[:])
+	binary.BigEndian.PutUint32(finalState[len(fHash):], uint32(fIdx))
+	finalState[len(fHash)+4] = byte(fHashOffset)
+
+	return chainhash.HashH(finalState)
+}
+
 // uniformRandom returns a random in the range [0, upperBound) while avoiding
 // modulo bias to ensure a normal distribution within the specified range.
 func (hp *hash256prng) uniformRandom(upperBound uint32) uint32 {
@@ -143,22 +154,26 @@ func (hp *hash256prng) uniformRandom(upperBound uint32) uint32 {
 }
 
 // stakeTicket represents a simulated sstx (stake ticket) along with the height
-// of the block it was simulated to be mined in and the height it wins in.
+// of the block it was simulated to be mined in, the height it wins in, and the
+// owner that purchased it.
 type stakeTicket struct {
-	hash        chainhash.Hash
-	blockHeight int32
-	price       dcrutil.Amount
-	winHeight   int32
+	hash         chainhash.Hash
+	blockHeight  int32
+	price        dcrutil.Amount
+	winHeight    int32
+	revokeHeight int32
+	owner        uint32
 }
 
 // newStakeTicket returns a new simulated stake ticket with the given hash and
 // purchased at the provided height.
 func newStakeTicket(hash *chainhash.Hash, purchaseHeight int32, price int64) *stakeTicket {
 	return &stakeTicket{
-		hash:        *hash,
-		blockHeight: purchaseHeight,
-		price:       dcrutil.Amount(price),
-		winHeight:   -1,
+		hash:         *hash,
+		blockHeight:  purchaseHeight,
+		price:        dcrutil.Amount(price),
+		winHeight:    -1,
+		revokeHeight: -1,
 	}
 }
 
@@ -196,18 +211,25 @@ func (s uint32Sorter) Less(i, j int) bool {
 }
 
 // winningTickets returns a slice of tickets that are required to vote for the
-// given block being voted on and current live ticket pool.
-func winningTickets(voteBlock *blockNode, liveTickets *tickettreap.Immutable, numVotes uint16) ([]*stakeTicket, error) {
+// given block being voted on and current live ticket pool along with the final
+// state of the lottery.  The final state is a checksum of the winning tickets,
+// in the order they were selected, and the final state of the deterministic
+// prng, which is the value committed to by the FinalState field of the header
+// of the block the tickets vote in.
+func winningTickets(voteBlock *blockNode, liveTickets *tickettreap.Immutable, numVotes uint16) ([]*stakeTicket, [6]byte, error) {
+	var finalState [6]byte
+
 	// Ensure the number of live tickets is within the allowable range.
 	numLiveTickets := uint32(liveTickets.Len())
 	if numLiveTickets > math.MaxUint32 {
-		return nil, fmt.Errorf("live ticket pool has %d tickets which "+
-			"is more than the max allowed of %d", numLiveTickets,
-			math.MaxUint32)
+		return nil, finalState, fmt.Errorf("live ticket pool has %d "+
+			"tickets which is more than the max allowed of %d",
+			numLiveTickets, math.MaxUint32)
 	}
 	if uint32(numVotes) > numLiveTickets {
-		return nil, fmt.Errorf("live ticket pool has %d tickets, "+
-			"while %d are needed to vote", numLiveTickets, numVotes)
+		return nil, finalState, fmt.Errorf("live ticket pool has %d "+
+			"tickets, while %d are needed to vote", numLiveTickets,
+			numVotes)
 	}
 
 	// Construct list of winners by generating successive values from the
@@ -223,28 +245,37 @@ func winningTickets(voteBlock *blockNode, liveTickets *tickettreap.Immutable, nu
 			winningOffsets = append(winningOffsets, ticketIndex)
 		}
 	}
+	selectedOffsets := make([]uint32, len(winningOffsets))
+	copy(selectedOffsets, winningOffsets)
 	sort.Sort(uint32Sorter(winningOffsets))
 
 	// Reconstruct the winning stake tickets based upon the winning indices.
 	winners := make([]*stakeTicket, 0, numVotes)
-	var poolIdx, winnerIdx uint32
-	liveTickets.ForEach(func(key tickettreap.Key, val *tickettreap.Value) bool {
-		if poolIdx == winningOffsets[winnerIdx] {
-			ticketHash := (*chainhash.Hash)(&key)
-			ticket := newStakeTicket(ticketHash, val.PurchaseHeight,
-				val.PurchasePrice)
-			ticket.winHeight = voteBlock.height + 1
-			winners = append(winners, ticket)
-			if uint16(len(winners)) == numVotes {
-				return false
-			}
-			winnerIdx++
-		}
-		poolIdx++
-		return true
-	})
+	for _, offset := range winningOffsets {
+		key, val := liveTickets.FetchIndex(offset)
+		ticketHash := (*chainhash.Hash)(&key)
+		ticket := newStakeTicket(ticketHash, val.PurchaseHeight,
+			val.PurchasePrice)
+		ticket.winHeight = voteBlock.height + 1
+		ticket.owner = val.Owner
+		winners = append(winners, ticket)
+	}
 
-	return winners, nil
+	// Calculate the final state of the lottery from the winning tickets in
+	// the order they were selected followed by the final prng state.
+	winnersByOffset := make(map[uint32]*stakeTicket, len(winners))
+	for i, winner := range winners {
+		winnersByOffset[winningOffsets[i]] = winner
+	}
+	stateBuffer := make([]byte, 0, (int(numVotes)+1)*chainhash.HashSize)
+	for _, offset := range selectedOffsets {
+		stateBuffer = append(stateBuffer, winnersByOffset[offset].hash[:]...)
+	}
+	lastHash := prng.stateHash()
+	stateBuffer = append(stateBuffer, lastHash[:]...)
+	copy(finalState[:], chainhash.HashB(stateBuffer)[0:6])
+
+	return winners, finalState, nil
 }
 
 // blockNode represent a block in the simulated chain along with additional data
@@ -258,6 +289,11 @@ type blockNode struct {
 	ticketPrice    int64          // Stake difficulty target.
 	regularSubsidy dcrutil.Amount // PoW and dev subsidies of this block.
 	poolSize       uint32         // Total pool size as of this block.
+	finalState     [6]byte        // Final state of the lottery voted on.
+
+	// These fields are snapshots of the coin supply as of this block.
+	totalSupply     dcrutil.Amount
+	spendableSupply dcrutil.Amount
 
 	numVoters      uint16
 	ticketsAdded   []*stakeTicket
@@ -310,7 +346,38 @@ type simulator struct {
 	spendableSupply dcrutil.Amount
 	maturingSupply  map[int32]dcrutil.Amount
 
+	// These fields track the blocks invalidated by stakeholders and the
+	// total PoW and dev subsidy that was removed from the supply as a
+	// result.
+	invalidatedBlocks  uint32
+	invalidatedSubsidy dcrutil.Amount
+
+	// These fields are related to the behavior modelled by the full
+	// simulation.  All randomness is derived from the seed so that runs
+	// can be reproduced.
+	seed             int64
+	rngSource        *countingSource
+	rng              *rand.Rand
+	demandModel      demandModel
+	demandPerWindow  int32
+	demandComputed   bool
+	agents           *agentPopulation
+	ownership        *ownershipLedger
+	voteModel        missedVoteModel
+	revocationModel  *revocationModel
+	invalidationProb float64
+	rampUpRate       float64
+	stakingCap       float64
+	events           *eventSchedule
+	validator        *replayValidator
+
+	algoName            string
+	algoSchedule        stakeDiffSchedule
 	nextTicketPriceFunc func() int64
+
+	// quiet suppresses the progress reports, which is used when many
+	// simulations are run.
+	quiet bool
 }
 
 // calcFullSubsidy returns the full block subsidy for the given block height.
@@ -642,6 +709,225 @@ func (s *simulator) curCalcNextStakeDiff() int64 {
 	return nextDiff
 }
 
+// estimateSupply returns an estimate of the coin supply for the provided block
+// height.  This is primarily used in the stake difficulty algorithm defined by
+// DCP0001 and relies on an estimate to simplify the necessary calculations.
+// The actual total coin supply as of a given block height depends on many
+// factors such as the number of votes included in every prior block (not
+// including all votes reduces the subsidy) and whether or not any of the prior
+// blocks have been invalidated by stakeholders thereby removing the PoW subsidy
+// for them.
+func estimateSupply(params *chaincfg.Params, height int32) int64 {
+	if height <= 0 {
+		return 0
+	}
+
+	// Estimate the supply by calculating the full block subsidy for each
+	// reduction interval and multiplying it the number of blocks in the
+	// interval then adding the subsidy produced by number of blocks in the
+	// current interval.
+	supply := params.BlockOneSubsidy()
+	reductions := int64(height) / params.SubsidyReductionInterval
+	subsidy := params.BaseSubsidy
+	for i := int64(0); i < reductions; i++ {
+		supply += params.SubsidyReductionInterval * subsidy
+
+		subsidy *= params.MulSubsidy
+		subsidy /= params.DivSubsidy
+	}
+	supply += (1 + int64(height)%params.SubsidyReductionInterval) * subsidy
+
+	// Blocks 0 and 1 have special subsidy amounts that have already been
+	// added above, so remove what their subsidies would have normally been
+	// which were also added above.
+	supply -= params.BaseSubsidy * 2
+
+	return supply
+}
+
+// sumPurchasedTickets returns the sum of the number of tickets purchased in the
+// most recent specified number of blocks from the point of view of the passed
+// node.
+func sumPurchasedTickets(startNode *blockNode, numToSum int32) int64 {
+	var numPurchased int64
+	for node, numTraversed := startNode, int32(0); node != nil &&
+		numTraversed < numToSum; numTraversed++ {
+
+		numPurchased += int64(len(node.ticketsAdded))
+		node = node.parent
+	}
+	return numPurchased
+}
+
+// calcNextStakeDiffV2 calculates the next stake difficulty for the given set
+// of parameters using the algorithm defined in DCP0001.
+//
+// This function contains the heart of the algorithm and thus is separated for
+// use in both the actual stake difficulty calculation as well as estimation.
+//
+// The caller must perform all of the necessary chain traversal in order to
+// get the current difficulty, previous retarget interval's pool size plus
+// its immature tickets, as well as the current pool size plus immature tickets.
+func calcNextStakeDiffV2(params *chaincfg.Params, nextHeight int32, curDiff, prevPoolSizeAll, curPoolSizeAll int64) int64 {
+	// Shorter version of various parameter for convenience.
+	votesPerBlock := int64(params.TicketsPerBlock)
+	ticketPoolSize := int64(params.TicketPoolSize)
+	ticketMaturity := int64(params.TicketMaturity)
+
+	// Calculate the difficulty by multiplying the old stake difficulty
+	// with two ratios that represent a force to counteract the relative
+	// change in the pool size (Fc) and a restorative force to push the pool
+	// size towards the target value (Fr).
+	//
+	// Per DCP0001, the generalized equation is:
+	//
+	//   nextDiff = min(max(curDiff * Fc * Fr, Slb), Sub)
+	//
+	// The detailed form expands to:
+	//
+	//                        curPoolSizeAll      curPoolSizeAll
+	//   nextDiff = curDiff * ---------------  * -----------------
+	//                        prevPoolSizeAll    targetPoolSizeAll
+	//
+	//   Slb = params.MinimumStakeDiff
+	//
+	//               estimatedTotalSupply
+	//   Sub = -------------------------------
+	//          targetPoolSize / votesPerBlock
+	//
+	// In order to avoid the need to perform floating point math which could
+	// be problematic across languages due to uncertainty in floating point
+	// math libs, this is further simplified to integer math as follows:
+	//
+	//                   curDiff * curPoolSizeAll^2
+	//   nextDiff = -----------------------------------
+	//              prevPoolSizeAll * targetPoolSizeAll
+	//
+	// Further, the Sub parameter must calculate the denomitor first using
+	// integer math.
+	targetPoolSizeAll := votesPerBlock * (ticketPoolSize + ticketMaturity)
+	curPoolSizeAllBig := big.NewInt(curPoolSizeAll)
+	nextDiffBig := big.NewInt(curDiff)
+	nextDiffBig.Mul(nextDiffBig, curPoolSizeAllBig)
+	nextDiffBig.Mul(nextDiffBig, curPoolSizeAllBig)
+	nextDiffBig.Div(nextDiffBig, big.NewInt(prevPoolSizeAll))
+	nextDiffBig.Div(nextDiffBig, big.NewInt(targetPoolSizeAll))
+
+	// Limit the new stake difficulty between the minimum allowed stake
+	// difficulty and a maximum value that is relative to the total supply.
+	//
+	// NOTE: This is intentionally using integer math to prevent any
+	// potential issues due to uncertainty in floating point math libs.  The
+	// ticketPoolSize parameter already contains the result of
+	// (targetPoolSize / votesPerBlock).
+	nextDiff := nextDiffBig.Int64()
+	estimatedSupply := estimateSupply(params, nextHeight)
+	maximumStakeDiff := estimatedSupply / ticketPoolSize
+	if nextDiff > maximumStakeDiff {
+		nextDiff = maximumStakeDiff
+	}
+	if nextDiff < params.MinimumStakeDiff {
+		nextDiff = params.MinimumStakeDiff
+	}
+	return nextDiff
+}
+
+// calcNextStakeDiffDCP0001 returns the required stake difficulty (aka ticket
+// price) for the block after the current tip block the generator is associated
+// with using the algorithm defined in DCP0001 which replaced the March 2017
+// algorithm on mainnet.
+//
+// An overview of the algorithm is as follows:
+// 1) Use the minimum value for any blocks before any tickets could have
+//    possibly been purchased due to coinbase maturity requirements
+// 2) Use the previous block's difficulty if the next block is not at a retarget
+//    interval
+// 3) Use the previous block's difficulty when there were no live or immature
+//    tickets as of the previous retarget interval
+// 4) Scale the previous difficulty by both the relative change in the number
+//    of live and immature tickets since the previous retarget interval and the
+//    ratio of that number to the target and limit the result to the minimum
+//    stake difficulty and a maximum that is relative to the estimated supply
+func (s *simulator) calcNextStakeDiffDCP0001() int64 {
+	// Stake difficulty before any tickets could possibly be purchased is
+	// the minimum value.
+	nextHeight := int32(0)
+	if s.tip != nil {
+		nextHeight = s.tip.height + 1
+	}
+	stakeDiffStartHeight := int32(s.params.CoinbaseMaturity) + 1
+	if nextHeight < stakeDiffStartHeight {
+		return s.params.MinimumStakeDiff
+	}
+
+	// Return the previous block's difficulty requirements if the next block
+	// is not at a difficulty retarget interval.
+	intervalSize := int32(s.params.StakeDiffWindowSize)
+	curDiff := s.tip.ticketPrice
+	if nextHeight%intervalSize != 0 {
+		return curDiff
+	}
+
+	// Get the pool size and number of tickets that were immature at the
+	// previous retarget interval.
+	//
+	// NOTE: Since the stake difficulty must be calculated based on existing
+	// blocks, it is always calculated for the block after a given block, so
+	// the information for the previous retarget interval must be retrieved
+	// relative to the block just before it to coincide with how it was
+	// originally calculated.
+	var prevPoolSize int64
+	prevRetargetHeight := nextHeight - intervalSize - 1
+	prevRetargetNode := s.ancestorNode(s.tip, prevRetargetHeight, nil)
+	if prevRetargetNode != nil {
+		prevPoolSize = int64(prevRetargetNode.poolSize)
+	}
+	ticketMaturity := int32(s.params.TicketMaturity)
+	prevImmatureTickets := sumPurchasedTickets(prevRetargetNode,
+		ticketMaturity)
+
+	// Return the existing ticket price for the first few intervals to avoid
+	// division by zero and encourage initial pool population.
+	prevPoolSizeAll := prevPoolSize + prevImmatureTickets
+	if prevPoolSizeAll == 0 {
+		return curDiff
+	}
+
+	// Count the number of currently immature tickets.
+	immatureTickets := sumPurchasedTickets(s.tip, ticketMaturity)
+
+	// Calculate and return the final next required difficulty.
+	curPoolSizeAll := int64(s.tip.poolSize) + immatureTickets
+	return calcNextStakeDiffV2(s.params, nextHeight, curDiff,
+		prevPoolSizeAll, curPoolSizeAll)
+}
+
+// dcp0001ActivationHeights houses the heights at which the stake difficulty
+// algorithm defined by DCP0001 activated keyed by network name.
+var dcp0001ActivationHeights = map[string]int32{
+	chaincfg.MainNetParams.Name: 149248,
+}
+
+// calcNextStakeDiffMainnet returns the required stake difficulty (aka ticket
+// price) for the block after the current tip block the generator is associated
+// with using the same rules mainnet does.  That is to say it uses the March
+// 2017 algorithm prior to the activation height of DCP0001 and the DCP0001
+// algorithm afterwards.
+//
+// Networks that do not have a known activation height use the DCP0001
+// algorithm for all blocks.
+func (s *simulator) calcNextStakeDiffMainnet() int64 {
+	nextHeight := int32(0)
+	if s.tip != nil {
+		nextHeight = s.tip.height + 1
+	}
+	activationHeight, ok := dcp0001ActivationHeights[s.params.Name]
+	if ok && nextHeight < activationHeight {
+		return s.curCalcNextStakeDiff()
+	}
+	return s.calcNextStakeDiffDCP0001()
+}
+
 // removeTicket removes the passed index from the provided slice of tickets and
 // returns the resulting slice.  This is an in-place modification.
 func removeTicket(tickets []*stakeTicket, index int) []*stakeTicket {
@@ -659,6 +945,7 @@ func (s *simulator) connectLiveTickets(height int32, winners, purchases []*stake
 	for _, winner := range winners {
 		s.liveTickets = s.liveTickets.Delete(tickettreap.Key(winner.hash))
 		s.wonTickets = append(s.wonTickets, winner)
+		s.ownership.ticketLeft(winner.owner)
 	}
 
 	// Move expired tickets from the live ticket pool to the expired and
@@ -667,7 +954,8 @@ func (s *simulator) connectLiveTickets(height int32, winners, purchases []*stake
 	for _, ticket := range tickets {
 		if s.liveTickets.Has(tickettreap.Key(ticket.hash)) {
 			s.expiredTickets = append(s.expiredTickets, ticket)
-			s.unrevokedTickets = append(s.unrevokedTickets, ticket)
+			s.addUnrevokedTickets([]*stakeTicket{ticket}, height)
+			s.ownership.ticketLeft(ticket.owner)
 		}
 		s.liveTickets = s.liveTickets.Delete(tickettreap.Key(ticket.hash))
 	}
@@ -685,7 +973,9 @@ func (s *simulator) connectLiveTickets(height int32, winners, purchases []*stake
 				&tickettreap.Value{
 					PurchaseHeight: ticket.blockHeight,
 					PurchasePrice:  int64(ticket.price),
+					Owner:          ticket.owner,
 				})
+			s.ownership.ticketLive(ticket.owner)
 
 			// This is required because the ticket at the current
 			// offset was just removed from the slice that is being
@@ -714,9 +1004,25 @@ type simData struct {
 	prevValid    bool
 	newTickets   uint8
 	ticketHashes []chainhash.Hash // Optional
+	ticketOwners []uint32         // Optional
 	revocations  uint16
 }
 
+// simDataError describes a sanity check failure of the data used to drive the
+// simulation.  It identifies the height of the block the data is for and the
+// offending field so that malformed or forked input data can be diagnosed.
+type simDataError struct {
+	height      int32
+	field       string
+	description string
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *simDataError) Error() string {
+	return fmt.Sprintf("invalid %s in simulation data for height %d: %s",
+		e.field, e.height, e.description)
+}
+
 // nextNode generates a node the builds from the current simulator tip using the
 // passed data to obtain the specific details such as the number of new tickets
 // to purchase, how many tickets to revoke, and the number of voters and makes
@@ -725,7 +1031,10 @@ type simData struct {
 // It also includes sanity checking on the input data and performs various
 // bookkeeping such as tracking the live ticket pool, winning tickets, subsidy
 // generation per number of voters in the input data, and total coin supply.
-func (s *simulator) nextNode(data *simData) *blockNode {
+//
+// A simDataError is returned when the input data fails the sanity checks, in
+// which case the state of the simulator is not modified.
+func (s *simulator) nextNode(data *simData) (*blockNode, error) {
 	var nextHeight int32
 	if s.tip != nil {
 		nextHeight = s.tip.height + 1
@@ -738,61 +1047,73 @@ func (s *simulator) nextNode(data *simData) *blockNode {
 	ticketMaturity := int32(s.params.TicketMaturity)
 
 	// Perform a bit of sanity checking on the simulation input data.
+	dataError := func(field, format string, args ...interface{}) error {
+		return &simDataError{
+			height:      nextHeight,
+			field:       field,
+			description: fmt.Sprintf(format, args...),
+		}
+	}
 	if data.newTickets > s.params.MaxFreshStakePerBlock {
-		panic(fmt.Sprintf("Simulation data attempted to purchase "+
-			"%d new tickets at height %d which is greater than "+
-			"max allowed per block %d", data.newTickets, nextHeight,
-			s.params.MaxFreshStakePerBlock))
+		return nil, dataError("newTickets", "attempted to purchase %d "+
+			"new tickets which is greater than max allowed per "+
+			"block %d", data.newTickets,
+			s.params.MaxFreshStakePerBlock)
+	}
+	if data.ticketHashes != nil &&
+		len(data.ticketHashes) != int(data.newTickets) {
+
+		return nil, dataError("ticketHashes", "provided %d ticket "+
+			"hashes for %d new tickets", len(data.ticketHashes),
+			data.newTickets)
 	}
 	if data.voters > ticketsPerBlock {
-		panic(fmt.Sprintf("Simulation data attempted to include %d "+
-			"votes at height %d which is greater than max allowed "+
-			"per block %d", data.voters, nextHeight,
-			ticketsPerBlock))
+		return nil, dataError("voters", "attempted to include %d votes "+
+			"which is greater than max allowed per block %d",
+			data.voters, ticketsPerBlock)
 	}
 	if int(data.revocations) > len(s.unrevokedTickets) {
-		panic(fmt.Sprintf("Simulation data attempted to revoke %d "+
-			"tickets at height %d which is greater than unrevoked "+
-			"tickets %d", data.revocations, nextHeight,
-			len(s.unrevokedTickets)))
+		return nil, dataError("revocations", "attempted to revoke %d "+
+			"tickets which is greater than unrevoked tickets %d",
+			data.revocations, len(s.unrevokedTickets))
 	}
 	if int64(nextHeight) >= stakeValidationHeight &&
 		data.voters < (ticketsPerBlock/2+1) {
-		panic(fmt.Sprintf("Simulation data attempted to include %d "+
-			"votes at height %d which is less than min allowed "+
-			"per block %d", data.voters, nextHeight,
-			(ticketsPerBlock/2 + 1)))
+		return nil, dataError("voters", "attempted to include %d votes "+
+			"which is less than min allowed per block %d",
+			data.voters, (ticketsPerBlock/2 + 1))
 	}
 	if nextHeight <= int32(s.params.CoinbaseMaturity) {
 		if data.newTickets != 0 {
-			panic(fmt.Sprintf("Simulation data attempted to "+
-				"purchase %d new tickets at height %d before "+
-				"any coins are spendable", data.newTickets,
-				nextHeight))
+			return nil, dataError("newTickets", "attempted to "+
+				"purchase %d new tickets before any coins are "+
+				"spendable", data.newTickets)
 		}
 	} else if int64(nextHeight) < stakeValidationHeight {
 		if data.voters != 0 {
-			panic(fmt.Sprintf("Simulation data attempted to "+
-				"vote with %d tickets at height %d before "+
-				"stake validation height %d", data.voters,
-				nextHeight, stakeValidationHeight))
+			return nil, dataError("voters", "attempted to vote with "+
+				"%d tickets before stake validation height %d",
+				data.voters, stakeValidationHeight)
 		}
 		if data.revocations != 0 {
-			panic(fmt.Sprintf("Simulation data attempted to "+
-				"revoke %d tickets at height %d before stake "+
-				"validation height %d", data.revocations,
-				nextHeight, stakeValidationHeight))
+			return nil, dataError("revocations", "attempted to "+
+				"revoke %d tickets before stake validation "+
+				"height %d", data.revocations,
+				stakeValidationHeight)
 		}
 	}
 
 	// Generate votes once the stake validation height has been reached.
 	var ticketsWon, ticketsVoted, ticketsMissed []*stakeTicket
+	var finalState [6]byte
 	if int64(nextHeight) >= stakeValidationHeight {
-		winners, err := winningTickets(s.tip, s.liveTickets,
+		winners, state, err := winningTickets(s.tip, s.liveTickets,
 			ticketsPerBlock)
 		if err != nil {
-			panic(err)
+			return nil, dataError("liveTickets", "unable to select "+
+				"winning tickets: %v", err)
 		}
+		finalState = state
 
 		ticketsWon = winners
 		ticketsVoted = winners[:data.voters]
@@ -804,6 +1125,14 @@ func (s *simulator) nextNode(data *simData) *blockNode {
 	s.spendableSupply += s.maturingSupply[nextHeight]
 	delete(s.maturingSupply, nextHeight)
 
+	// Credit the stakeholders with the vote rewards and refunds of their
+	// tickets that mature in the new block.
+	for owner, amount := range s.ownership.maturedCredits(nextHeight) {
+		if s.agents != nil && int(owner) < len(s.agents.agents) {
+			s.agents.agents[owner].balance += amount
+		}
+	}
+
 	// Generate mock stake tickets for each new one purchased in the block
 	// and deduct the amount from the spendable supply since the coins will
 	// be locked.
@@ -825,6 +1154,10 @@ func (s *simulator) nextNode(data *simData) *blockNode {
 			ticketHash = stakeTicketHash(nextHeight, i)
 		}
 		ticket := newStakeTicket(&ticketHash, nextHeight, ticketPrice)
+		if data.ticketOwners != nil {
+			ticket.owner = data.ticketOwners[i]
+		}
+		s.ownership.recordPurchase(ticket)
 		ticketsAdded = append(ticketsAdded, ticket)
 		s.spendableSupply -= dcrutil.Amount(ticketPrice)
 	}
@@ -852,6 +1185,7 @@ func (s *simulator) nextNode(data *simData) *blockNode {
 	node.numVoters = data.voters
 	node.ticketPrice = ticketPrice
 	node.poolSize = uint32(s.liveTickets.Len())
+	node.finalState = finalState
 
 	// Calculate the total new supply generated by this block and keep a
 	// running tally of the total supply.  Also, keep track of when the
@@ -876,7 +1210,15 @@ func (s *simulator) nextNode(data *simData) *blockNode {
 		// subsidy generated by the previous block to the total supply
 		// if it wasn't invalidated.  This means the reported total
 		// supply is always one block behind what is actually available.
-		if !data.prevValid {
+		//
+		// Also, since the subsidy of the previous block was already
+		// scheduled to mature, remove it from the maturing supply when
+		// the block is invalidated since it will never be spendable.
+		if !data.prevValid && node.parent != nil {
+			parentMaturedHeight := node.parent.height + coinbaseMaturity
+			s.maturingSupply[parentMaturedHeight] -= parentRegularSubsidy
+			s.invalidatedBlocks++
+			s.invalidatedSubsidy += parentRegularSubsidy
 			parentRegularSubsidy = 0
 		}
 		newSupply := parentRegularSubsidy + voteSubsidy
@@ -892,9 +1234,12 @@ func (s *simulator) nextNode(data *simData) *blockNode {
 		s.maturingSupply[ticketMaturedHeight] += voteSubsidy
 		for _, ticket := range ticketsVoted {
 			s.maturingSupply[ticketMaturedHeight] += ticket.price
+			s.ownership.recordVote(ticket, perVoteSubsidy,
+				ticketMaturedHeight)
 		}
 		for _, ticket := range ticketsRevoked {
 			s.maturingSupply[ticketMaturedHeight] += ticket.price
+			s.ownership.recordRevocation(ticket, ticketMaturedHeight)
 		}
 	}
 
@@ -902,33 +1247,76 @@ func (s *simulator) nextNode(data *simData) *blockNode {
 	// removing the winning tickets, removing any tickets that are now
 	// expired, and updated related state.  Also, add missed tickets to the
 	// unrevoked tickets pool.
-	s.unrevokedTickets = append(s.unrevokedTickets, ticketsMissed...)
+	s.missedTickets = append(s.missedTickets, ticketsMissed...)
+	s.addUnrevokedTickets(ticketsMissed, nextHeight)
 	s.connectLiveTickets(nextHeight, ticketsWon, ticketsAdded)
+	if nextHeight%int32(s.params.StakeDiffWindowSize) == 0 {
+		s.ownership.samplePoolShare(nextHeight, s.liveTickets.Len())
+	}
+	node.totalSupply = s.totalSupply
+	node.spendableSupply = s.spendableSupply
 	s.tip = node
 	if s.root == nil {
 		s.root = node
 	}
-	return node
+	return node, nil
 }
 
+const (
+	// defaultRampUpRate is the default fraction of the maximum number of
+	// new tickets per block purchased prior to the stake validation height
+	// in full simulations in order to ramp up the ticket pool.
+	defaultRampUpRate = 0.5
+
+	// defaultStakingCap is the default maximum fraction of the total supply
+	// that is staked in full simulations.  No tickets are purchased while
+	// the staked coins exceed it.
+	defaultStakingCap = 0.4
+)
+
 // newSimulator returns an instance of a type that can be used to perform
 // proof-of-stake simulations.
 func newSimulator(params *chaincfg.Params) *simulator {
-	return &simulator{
-		params:         params,
-		liveTickets:    tickettreap.NewImmutable(),
-		expireHeights:  make(map[int32][]*stakeTicket),
-		maturingSupply: make(map[int32]dcrutil.Amount),
+	maxTicketsPerWindow := int32(params.MaxFreshStakePerBlock) *
+		int32(params.StakeDiffWindowSize)
+	s := &simulator{
+		params:          params,
+		liveTickets:     tickettreap.NewImmutable(),
+		expireHeights:   make(map[int32][]*stakeTicket),
+		maturingSupply:  make(map[int32]dcrutil.Amount),
+		ownership:       newOwnershipLedger(),
+		demandModel:     newDefaultDemandModel(),
+		demandPerWindow: maxTicketsPerWindow,
+		voteModel:       &perfectVoteModel{},
+		rampUpRate:      defaultRampUpRate,
+		stakingCap:      defaultStakingCap,
+		events:          &eventSchedule{},
 	}
+	s.setSeed(time.Now().UnixNano())
+	return s
+}
+
+// setSeed sets the seed used to initialize all of the random number generators
+// used throughout the simulation, including the one used to assign priorities
+// in the ticket treap, to the provided value.
+func (s *simulator) setSeed(seed int64) {
+	s.seed = seed
+	s.rngSource = newCountingSource(seed)
+	s.rng = rand.New(s.rngSource)
+	tickettreap.Seed(seed)
 }
 
-// generateResults creates an HTML results file for a completed simulation and
-// opens it using a browser.
-func generateResults(s *simulator) error {
+// defaultResultsPath returns the path the HTML results file is written to when
+// no path is specified.  It is a timestamped file in the temp directory.
+func defaultResultsPath() string {
 	fileName := fmt.Sprintf("dcrstakesim-%s.html", time.Now().
 		Format("2006-01-02-150405"))
-	resultsPath := filepath.Join(os.TempDir(), fileName)
+	return filepath.Join(os.TempDir(), fileName)
+}
 
+// generateResults creates an HTML results file for a completed simulation at
+// the provided path.
+func generateResults(s *simulator, resultsPath string) error {
 	// Parse the results template.
 	resultsTpl, err := template.New("results").Parse(resultsTmplText)
 	if err != nil {
@@ -940,14 +1328,10 @@ func generateResults(s *simulator) error {
 	}
 	defer resultsFile.Close()
 
-	// Shorter version of some params for convenience.
-	stakeValidationHeight := int32(s.params.StakeValidationHeight)
-
 	// Generate the data needed for the HTML template and execute it in
 	// order to generate the final HTML results file.
+	summary := s.summarizeResults()
 	var poolSizeCSV, ticketPriceCSV bytes.Buffer
-	minTicketPrice, maxTicketPrice := int64(math.MaxInt64), int64(0)
-	minPoolSize, maxPoolSize := uint32(math.MaxUint32), uint32(0)
 	for node := s.root; node != nil; node = node.next {
 		heightStr := strconv.Itoa(int(node.height))
 		poolSizeCSV.WriteString(heightStr)
@@ -963,49 +1347,42 @@ func generateResults(s *simulator) error {
 			ticketPriceCSV.WriteString(priceStr)
 			ticketPriceCSV.WriteRune('\n')
 		}
-
-		if node.ticketPrice < minTicketPrice {
-			minTicketPrice = node.ticketPrice
-		}
-		if node.ticketPrice > maxTicketPrice {
-			maxTicketPrice = node.ticketPrice
-		}
-
-		// Only consider pool size after stake validation height unless
-		// the entire simulation is before that point.
-		if node.height >= stakeValidationHeight || s.tip.height < stakeValidationHeight {
-			if node.poolSize < minPoolSize {
-				minPoolSize = node.poolSize
-			}
-			if node.poolSize > maxPoolSize {
-				maxPoolSize = node.poolSize
-			}
-		}
 	}
-	totalTickets := s.liveTickets.Len() + len(s.wonTickets) +
-		len(s.expiredTickets)
+	var stakeholders []map[string]string
+	if s.agents != nil {
+		stakeholders = s.agents.results()
+	}
+	poolShareCSV, poolShareLabels := s.poolShareCSV()
 	//expired :=
-	err = resultsTpl.Execute(resultsFile, map[string]string{
-		"PoolSizeCSV":     poolSizeCSV.String(),
-		"TicketPriceCSV":  ticketPriceCSV.String(),
-		"MinTicketPrice":  dcrutil.Amount(minTicketPrice).String(),
-		"MaxTicketPrice":  dcrutil.Amount(maxTicketPrice).String(),
-		"NumTickets":      strconv.FormatUint(uint64(totalTickets), 10),
-		"NumWinners":      strconv.FormatUint(uint64(len(s.wonTickets)), 10),
-		"NumExpired":      strconv.FormatUint(uint64(len(s.expiredTickets)), 10),
-		"MinPoolSize":     strconv.FormatUint(uint64(minPoolSize), 10),
-		"MaxPoolSize":     strconv.FormatUint(uint64(maxPoolSize), 10),
-		"CoinSupply":      s.totalSupply.String(),
-		"SpendableSupply": s.spendableSupply.String(),
+	err = resultsTpl.Execute(resultsFile, map[string]interface{}{
+		"PoolSizeCSV":        poolSizeCSV.String(),
+		"TicketPriceCSV":     ticketPriceCSV.String(),
+		"MinTicketPrice":     summary.MinTicketPrice.String(),
+		"MaxTicketPrice":     summary.MaxTicketPrice.String(),
+		"NumTickets":         strconv.Itoa(summary.NumTickets),
+		"NumWinners":         strconv.Itoa(summary.NumWinners),
+		"NumExpired":         strconv.Itoa(summary.NumExpired),
+		"NumMissed":          strconv.Itoa(summary.NumMissed),
+		"NumUnrevoked":       strconv.Itoa(summary.NumUnrevoked),
+		"UnrevokedAmount":    summary.UnrevokedAmount.String(),
+		"NumInvalidated":     strconv.FormatUint(uint64(summary.NumInvalidated), 10),
+		"InvalidatedSubsidy": summary.InvalidatedSubsidy.String(),
+		"MinPoolSize":        strconv.FormatUint(uint64(summary.MinPoolSize), 10),
+		"MaxPoolSize":        strconv.FormatUint(uint64(summary.MaxPoolSize), 10),
+		"Seed":               strconv.FormatInt(summary.Seed, 10),
+		"CoinSupply":         summary.CoinSupply.String(),
+		"SpendableSupply":    summary.SpendableSupply.String(),
+		"Stakeholders":       stakeholders,
+		"Owners":             ownerResults(summary.Owners),
+		"Stability":          summary.Stability.formatted(),
+		"PoolShareCSV":       poolShareCSV,
+		"PoolShareLabels":    poolShareLabels,
+		"Algorithm":          s.algoName,
+		"Activations":        s.algoSchedule.activationHeights(),
 	})
 	if err != nil {
 		return fmt.Errorf("unable to execute template: %v", err)
 	}
 
-	if !openBrowser(resultsPath) {
-		return fmt.Errorf("unable to open results file %q in browser",
-			resultsPath)
-	}
-
-	return nil
+	return resultsFile.Close()
 }
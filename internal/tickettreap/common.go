@@ -6,6 +6,7 @@
 package tickettreap
 
 import (
+	"fmt"
 	"math/rand"
 	"sync"
 	"time"
@@ -26,11 +27,12 @@ const (
 	// technically it is smaller on 32-bit platforms, but overestimating the
 	// size in that case is acceptable since it avoids the need to import
 	// unsafe.  It consists of 8 bytes for each of the value, priority,
-	// left, and right fields (8*4).
-	nodeFieldsSize = 32
+	// left, and right fields (8*4) plus 4 bytes for the size field rounded
+	// up to 8 for alignment.
+	nodeFieldsSize = 40
 
 	// nodeValueSize is the size of the fixed-size fields of a Value.
-	nodeValueSize = 12
+	nodeValueSize = 16
 )
 
 // lockedSource is a rng source that is safe for concurrent access.
@@ -68,6 +70,16 @@ var (
 	rng = rand.New(&lockedSource{src: rand.NewSource(time.Now().UnixNano())})
 )
 
+// Seed uses the provided seed value to initialize the random number generator
+// used to assign node priorities to a deterministic state.  This allows the
+// shape of the treap, and therefore the order in which memory is allocated, to
+// be reproduced.
+//
+// This function is safe for concurrent access.
+func Seed(seed int64) {
+	rng.Seed(seed)
+}
+
 // Key defines the key used to add an associated value to the treap.
 type Key chainhash.Hash
 
@@ -78,6 +90,9 @@ type Value struct {
 
 	// PurchasePrice is price of the original ticket.
 	PurchasePrice int64
+
+	// Owner identifies the stakeholder that purchased the ticket.
+	Owner uint32
 }
 
 // treapNode represents a node in the treap.
@@ -85,6 +100,7 @@ type treapNode struct {
 	key      Key
 	value    *Value
 	priority int
+	size     uint32 // Number of items in the subtree rooted at the node.
 	left     *treapNode
 	right    *treapNode
 }
@@ -98,7 +114,51 @@ func nodeSize(node *treapNode) uint64 {
 // newTreapNode returns a new node from the given key, value, and priority.  The
 // node is not initially linked to any others.
 func newTreapNode(key Key, value *Value, priority int) *treapNode {
-	return &treapNode{key: key, value: value, priority: priority}
+	return &treapNode{key: key, value: value, priority: priority, size: 1}
+}
+
+// leftSize returns the number of items in the left subtree of the node.
+func (t *treapNode) leftSize() uint32 {
+	if t.left != nil {
+		return t.left.size
+	}
+	return 0
+}
+
+// rightSize returns the number of items in the right subtree of the node.
+func (t *treapNode) rightSize() uint32 {
+	if t.right != nil {
+		return t.right.size
+	}
+	return 0
+}
+
+// updateSize recalculates the number of items within the treap rooted at the
+// node from the sizes of its children.  It must be called whenever the children
+// of the node change.
+func (t *treapNode) updateSize() {
+	t.size = 1 + t.leftSize() + t.rightSize()
+}
+
+// getByIndex returns the key/value pair at the given position within the treap
+// rooted at the node in ascending key order.  It panics if the index is out of
+// bounds.
+func (t *treapNode) getByIndex(idx uint32) (Key, *Value) {
+	if t == nil || idx >= t.size {
+		panic(fmt.Sprintf("getByIndex(%d) index out of bounds", idx))
+	}
+	node := t
+	for {
+		leftSize := node.leftSize()
+		switch {
+		case idx < leftSize:
+			node = node.left
+		case idx == leftSize:
+			return node.key, node.value
+		default:
+			node, idx = node.right, idx-leftSize-1
+		}
+	}
 }
 
 // parentStack represents a stack of parent treap nodes that are used during
@@ -15,6 +15,7 @@ func cloneTreapNode(node *treapNode) *treapNode {
 		key:      node.key,
 		value:    node.value,
 		priority: node.priority,
+		size:     node.size,
 		left:     node.left,
 		right:    node.right,
 	}
@@ -170,6 +171,11 @@ func (t *Immutable) Put(key Key, value *Value) *Immutable {
 		parent.right = node
 	}
 
+	// All of the replaced ancestors now contain one more item.
+	for i := 0; i < parents.Len(); i++ {
+		parents.At(i).size++
+	}
+
 	// Perform any rotations needed to maintain the min-heap and replace
 	// the ancestors up to and including the tree root.
 	newRoot := parents.At(parents.Len() - 1)
@@ -188,6 +194,8 @@ func (t *Immutable) Put(key Key, value *Value) *Immutable {
 		} else {
 			node.left, parent.right = parent, node.left
 		}
+		parent.updateSize()
+		node.updateSize()
 
 		// Either set the new root of the tree when there is no
 		// grandparent or relink the grandparent to the node based on
@@ -265,6 +273,12 @@ func (t *Immutable) Delete(key Key) *Immutable {
 	delNode = newParents.Pop()
 	parent = newParents.At(0)
 
+	// All of the replaced ancestors will contain one less item once the
+	// node is deleted.
+	for i := 0; i < newParents.Len(); i++ {
+		newParents.At(i).size--
+	}
+
 	// Perform rotations to move the node to delete to a leaf position while
 	// maintaining the min-heap while replacing the modified children.
 	var child *treapNode
@@ -288,12 +302,17 @@ func (t *Immutable) Delete(key Key) *Immutable {
 		// is on.  This has the effect of moving the node to delete
 		// towards the bottom of the tree while maintaining the
 		// min-heap.
+		//
+		// The child takes the place of the node to delete, so it will
+		// contain all of the items the node did except the node itself.
 		child = cloneTreapNode(child)
+		child.size = delNode.size - 1
 		if isLeft {
 			child.right, delNode.left = delNode, child.right
 		} else {
 			child.left, delNode.right = delNode, child.left
 		}
+		delNode.updateSize()
 
 		// Either set the new root of the tree when there is no
 		// grandparent or relink the grandparent to the node based on
@@ -350,6 +369,14 @@ func (t *Immutable) ForEach(fn func(k Key, v *Value) bool) {
 	}
 }
 
+// FetchIndex returns the key/value pair at the given position within the treap
+// in ascending key order.  It panics if the index is out of bounds.
+//
+// This function is O(log n) in the number of items in the treap.
+func (t *Immutable) FetchIndex(idx uint32) (Key, *Value) {
+	return t.root.getByIndex(idx)
+}
+
 // NewImmutable returns a new empty immutable treap ready for use.  See the
 // documentation for the Immutable structure for more details.
 func NewImmutable() *Immutable {
@@ -0,0 +1,116 @@
+// Copyright (c) 2015-2016 The btcsuite developers
+// Copyright (c) 2016-2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tickettreap
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// randomKey returns a key with its first bytes drawn from the passed random
+// number generator.  Only a small key space is used so that puts of existing
+// keys and deletes of missing ones are exercised too.
+func randomKey(r *rand.Rand) Key {
+	var key Key
+	key[0] = byte(r.Intn(4))
+	key[1] = byte(r.Intn(256))
+	return key
+}
+
+// checkSubtreeSizes ensures the size of every node in the subtree rooted at the
+// passed node is the number of items in the subtree and returns it.
+func checkSubtreeSizes(t *testing.T, node *treapNode) uint32 {
+	if node == nil {
+		return 0
+	}
+	size := 1 + checkSubtreeSizes(t, node.left) +
+		checkSubtreeSizes(t, node.right)
+	if node.size != size {
+		t.Fatalf("node size %d does not match subtree size %d",
+			node.size, size)
+	}
+	return size
+}
+
+// checkIndices ensures the passed treap contains exactly the keys in the
+// provided map and that fetching every index returns the same key/value pair
+// as iterating the treap in order.
+func checkIndices(t *testing.T, testTreap *Immutable, want map[Key]int32) {
+	if gotLen := testTreap.Len(); gotLen != len(want) {
+		t.Fatalf("Len: unexpected length - got %d, want %d", gotLen,
+			len(want))
+	}
+	checkSubtreeSizes(t, testTreap.root)
+
+	var idx uint32
+	var prevKey *Key
+	testTreap.ForEach(func(k Key, v *Value) bool {
+		if prevKey != nil && bytes.Compare(prevKey[:], k[:]) >= 0 {
+			t.Fatalf("ForEach: key %x is not after %x", k, *prevKey)
+		}
+		prevKey = &k
+		height, ok := want[k]
+		if !ok || v.PurchaseHeight != height {
+			t.Fatalf("ForEach: unexpected key %x with height %d", k,
+				v.PurchaseHeight)
+		}
+
+		gotKey, gotValue := testTreap.FetchIndex(idx)
+		if gotKey != k || gotValue != v {
+			t.Fatalf("FetchIndex #%d: got key %x, want %x", idx,
+				gotKey, k)
+		}
+		idx++
+		return true
+	})
+	if int(idx) != len(want) {
+		t.Fatalf("ForEach: iterated %d keys, want %d", idx, len(want))
+	}
+}
+
+// TestImmutableFetchIndex ensures that fetching items by their index returns
+// the item at that position in key order while random keys are interleaved
+// between being put and deleted, including when existing keys are replaced.
+// It also ensures that older versions of the treap are not modified.
+func TestImmutableFetchIndex(t *testing.T) {
+	t.Parallel()
+
+	r := rand.New(rand.NewSource(1))
+	want := make(map[Key]int32)
+	testTreap := NewImmutable()
+	for i := int32(0); i < 5000; i++ {
+		prevTreap := testTreap
+		prevWant := make(map[Key]int32, len(want))
+		for k, v := range want {
+			prevWant[k] = v
+		}
+
+		key := randomKey(r)
+		if r.Intn(3) == 0 {
+			testTreap = testTreap.Delete(key)
+			delete(want, key)
+		} else {
+			testTreap = testTreap.Put(key, &Value{PurchaseHeight: i})
+			want[key] = i
+		}
+
+		if i%50 == 0 {
+			checkIndices(t, testTreap, want)
+			checkIndices(t, prevTreap, prevWant)
+		}
+	}
+	checkIndices(t, testTreap, want)
+
+	// Delete all of the remaining keys in random order.
+	for len(want) > 0 {
+		idx := uint32(r.Intn(len(want)))
+		key, _ := testTreap.FetchIndex(idx)
+		testTreap = testTreap.Delete(key)
+		delete(want, key)
+		checkIndices(t, testTreap, want)
+	}
+}
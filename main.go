@@ -10,9 +10,12 @@ import (
 	"flag"
 	"fmt"
 	"io"
-	//"math"
+	"math"
 	"math/big"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"runtime"
 	"runtime/pprof"
 	"strconv"
 	"strings"
@@ -32,34 +35,64 @@ const (
 
 // convertRecord converts the passed record, which is expected to be parsed from
 // a CSV file, and thus will be a slice of strings, into a struct with concrete
-// types.
-func convertRecord(record []string) (*simData, error) {
-	headerBytes, err := hex.DecodeString(record[1])
+// types.  The decoded block header is also returned so the caller can verify
+// the simulation against it.
+func convertRecord(record []string) (*simData, *wire.BlockHeader, error) {
+	headerBytes, header, err := decodeHeader(record[1])
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	ticketHashes, err := decodeTicketHashes(record[2], header.FreshStake)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newSimData(headerBytes, header, ticketHashes), header, nil
+}
+
+// decodeHeader decodes the passed hex-encoded serialized block header from the
+// CSV input data.  The serialized bytes are returned along with the header.
+func decodeHeader(headerHex string) ([]byte, *wire.BlockHeader, error) {
+	headerBytes, err := hex.DecodeString(headerHex)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid block header hex: %v", err)
 	}
 
 	var header wire.BlockHeader
 	if err := header.FromBytes(headerBytes); err != nil {
-		return nil, err
+		return nil, nil, fmt.Errorf("unable to decode block header: %v",
+			err)
 	}
+	return headerBytes, &header, nil
+}
+
+// decodeTicketHashes decodes the passed colon-separated list of ticket hashes
+// from the CSV input data and ensures there is one for each of the provided
+// number of new tickets.
+func decodeTicketHashes(hashList string, newTickets uint8) ([]chainhash.Hash, error) {
 	var hashStrings []string
-	if record[2] != "" {
-		hashStrings = strings.Split(record[2], ":")
+	if hashList != "" {
+		hashStrings = strings.Split(hashList, ":")
 	}
-	if len(hashStrings) != int(header.FreshStake) {
-		return nil, fmt.Errorf("%d ticket hashes in CSV for %d new tickets",
-			len(hashStrings), header.FreshStake)
+	if len(hashStrings) != int(newTickets) {
+		return nil, fmt.Errorf("%d ticket hashes in CSV for %d new "+
+			"tickets", len(hashStrings), newTickets)
 	}
 	ticketHashes := make([]chainhash.Hash, 0, len(hashStrings))
 	for _, hashString := range hashStrings {
 		hash, err := chainhash.NewHashFromStr(hashString)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("invalid ticket hash %q: %v",
+				hashString, err)
 		}
 		ticketHashes = append(ticketHashes, *hash)
 	}
+	return ticketHashes, nil
+}
 
+// newSimData returns the simulation data for the passed decoded block header
+// and ticket hashes from the CSV input data.  The ticket hashes may be nil in
+// which case mock hashes are generated for the new tickets.
+func newSimData(headerBytes []byte, header *wire.BlockHeader, ticketHashes []chainhash.Hash) *simData {
 	return &simData{
 		header:       headerBytes,
 		voters:       header.Voters,
@@ -67,12 +100,15 @@ func convertRecord(record []string) (*simData, error) {
 		newTickets:   header.FreshStake,
 		ticketHashes: ticketHashes,
 		revocations:  uint16(header.Revocations),
-	}, nil
+	}
 }
 
 // reportProgress periodically prints out the current simulator height to
 // stdout.
 func (s *simulator) reportProgress() {
+	if s.quiet {
+		return
+	}
 	if s.tip.height%10000 == 0 && s.tip.height != 0 {
 		fmt.Println()
 	}
@@ -84,7 +120,18 @@ func (s *simulator) reportProgress() {
 // simulateFromCSV runs the simulation using input data from a CSV file.  It is
 // realistically only intended to be used with data extracted from mainnet in
 // order to exactly replicate its live ticket pool.
-func (s *simulator) simulateFromCSV(csvPath string) error {
+//
+// When a verifier is provided, every simulated block is also compared against
+// the header it was created from.
+//
+// The simulation stops once the block at the passed stop height has been
+// simulated when it is greater than zero, otherwise the entire file is used.
+//
+// Errors due to invalid data identify the line of the file that contains it.
+// When the simulator has a validator, the data is replayed leniently instead,
+// which means the anomalies are recorded by the validator and the replay
+// continues when it is safe to do so.
+func (s *simulator) simulateFromCSV(csvPath string, verifier *replayVerifier, stopHeight int32) error {
 	// Open the simulation CSV data which is expected to be in the following
 	// format:
 	//
@@ -93,18 +140,27 @@ func (s *simulator) simulateFromCSV(csvPath string) error {
 	if err != nil {
 		return err
 	}
+	defer csvFile.Close()
 
 	// Create a new simulator using input from the CSV file.
 	r := csv.NewReader(csvFile)
 	r.FieldsPerRecord = fieldsPerRecord
 	var handledHeader bool
+	var lineNum int
 	for {
 		record, err := r.Read()
 		if err == io.EOF {
 			break
 		}
+		lineNum++
 		if err != nil {
-			return err
+			if s.validator == nil {
+				return err
+			}
+			s.validator.numRows++
+			s.validator.record(lineNum, s.validator.nextHeight,
+				"row", actionStopped, "%v", err)
+			continue
 		}
 
 		// Skip header fields if they exist.
@@ -116,65 +172,62 @@ func (s *simulator) simulateFromCSV(csvPath string) error {
 			}
 		}
 
-		// Convert the CSV to concrete data.
-		data, err := convertRecord(record)
-		if err != nil {
-			return err
+		// Convert the CSV to concrete data.  Rows that can't be replayed
+		// are skipped when replaying leniently.
+		var data *simData
+		var header *wire.BlockHeader
+		if s.validator != nil {
+			data, header = s.validator.convertRecord(record, lineNum)
+			if data == nil {
+				continue
+			}
+		} else {
+			data, header, err = convertRecord(record)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %v", csvPath, lineNum,
+					err)
+			}
+		}
+
+		// The replayed tickets do not belong to any of the simulated
+		// stakeholders when there are any.
+		if s.agents != nil {
+			data.ticketOwners = make([]uint32, data.newTickets)
+			for i := range data.ticketOwners {
+				data.ticketOwners[i] = s.agents.bootstrapOwner()
+			}
 		}
 
 		// Create a new node that extends the current tip using the
-		// simulation data and potentially report the progress.
-		s.nextNode(data)
+		// simulation data, verify it against the actual header when
+		// requested, and potentially report the progress.
+		node, err := s.nextNode(data)
+		if err != nil {
+			dataErr, ok := err.(*simDataError)
+			if !ok || s.validator == nil {
+				return fmt.Errorf("%s:%d: %v", csvPath, lineNum,
+					err)
+			}
+			s.validator.record(lineNum, dataErr.height,
+				dataErr.field, actionStopped, "%s",
+				dataErr.description)
+			continue
+		}
+		if verifier != nil {
+			verifier.verifyNode(node, header)
+		}
 		s.reportProgress()
+		if stopHeight > 0 && node.height >= stopHeight {
+			break
+		}
 	}
 
 	return nil
 }
 
-// calcYieldDemand returns a simulated demand (as a percentage of the number of
-// tickets to purchase within a given stake difficulty interval) based upon the
-// estimated yield purchasing a ticket would produce.
-func calcYieldDemand(ticketPrice, perVoteSubsidy int64) float64 {
-	// 100% demand when the yield is over 5%.
-	yield := float64(perVoteSubsidy) / float64(ticketPrice)
-	if yield > 0.05 {
-		return 1.0
-	}
-
-	// No demand when the yield is under 2%.
-	if yield < 0.02 {
-		return 0.0
-	}
-
-	// The yield is in between 2% and 5%, so create a linear demand
-	// accordingly.
-	return (yield - 0.02) / 0.03
-}
-
-// calcVWAPDemand returns a simulated demand (as a percentage of the number of
-// tickets to purchase within a given stake difficulty interval) based upon the
-// volume-weighted average ticket purchase of the previous ticket price windows.
-func calcVWAPDemand(ticketPrice, ticketVWAP int64) float64 {
-	// 100% demand when the ticket price is under 80% of the VWAP.
-	eightyPercentVWAP := (ticketVWAP * 8) / 10
-	if ticketPrice < eightyPercentVWAP {
-		return 1.0
-	}
-
-	// No demand when the ticket price is over 120% of the VWAP.
-	if ticketPrice > (ticketVWAP*12)/10 {
-		return 0.0
-	}
-
-	// The ticket price is in between 80% and 120% of the VWAP, so create
-	// a linear demand accordingly.
-	fortyPercentVWAP := (ticketVWAP * 4) / 10
-	return 1 - float64(ticketPrice-eightyPercentVWAP)/float64(fortyPercentVWAP)
-}
-
 // calcVWAP calculates and return the volume-weighted average ticket purchase
 // price for up to 'StakeDiffWindows' worth of the previous ticket price
-// windows.
+// windows.  Zero is returned when no tickets were purchased in the windows.
 func (s *simulator) calcPrevVWAP(prevNode *blockNode) int64 {
 	windowSize := int32(s.params.StakeDiffWindowSize)
 	stakeDiffWindows := int32(s.params.StakeDiffWindows)
@@ -201,33 +254,14 @@ func (s *simulator) calcPrevVWAP(prevNode *blockNode) int64 {
 		prevNode = prevNode.parent
 	}
 
+	// There is no VWAP when no tickets were purchased.  This can happen
+	// with networks that have short windows.
+	if totalTickets.Sign() == 0 {
+		return 0
+	}
 	return new(big.Int).Div(weightedSum, totalTickets).Int64()
 }
 
-// calcDemand returns a simulated demand (as a percentage of the number of
-// tickets to purchase within a given stake difficulty interval).
-func (s *simulator) calcDemand(nextHeight int32, ticketPrice int64) float64 {
-	// Calculate the demand based on yield.
-	ticketsPerBlock := s.params.TicketsPerBlock
-	posSubsidy := s.calcPoSSubsidy(nextHeight - 1)
-	perVoteSubsidy := posSubsidy / dcrutil.Amount(ticketsPerBlock)
-	yieldDemand := calcYieldDemand(ticketPrice, int64(perVoteSubsidy))
-
-	// Calculate the demand based on the volume-weighted average ticket
-	// purchase price.
-	currentVWAP := s.calcPrevVWAP(s.tip)
-	vwapDemand := calcVWAPDemand(ticketPrice, currentVWAP)
-
-	// The demand is the combination of the two unless there is full demand
-	// based on yield and no demand based on the VWAP, in which case there
-	// is 100% demand.
-	demand := yieldDemand * vwapDemand
-	if yieldDemand == 1.0 && vwapDemand == 0.0 {
-		demand = 1.0
-	}
-	return demand
-}
-
 // simulate runs the simulation using a calculated demand curve which models
 // how ticket purchasing would typically proceed based upon the price and the
 // VWAP.
@@ -240,63 +274,150 @@ func (s *simulator) simulate(numBlocks uint64) error {
 	maxNewTicketsPerBlock := int32(s.params.MaxFreshStakePerBlock)
 	maxTicketsPerWindow := maxNewTicketsPerBlock * stakeDiffWindowSize
 
-	demandPerWindow := maxTicketsPerWindow
 	for i := uint64(0); i < numBlocks; i++ {
 		var nextHeight int32
 		if s.tip != nil {
 			nextHeight = s.tip.height + 1
 		}
 
-		// Purchase tickets according to simulated demand curve.
+		// Apply any change to the staking cap scripted to occur at
+		// this height.
+		if stakingCap, ok := s.events.stakingCap(nextHeight); ok {
+			s.stakingCap = stakingCap
+		}
+
+		// TODO(davec): Account for tickets being purchased.
+		// Limit the total staked coins to the configured fraction of
+		// the total supply, which defaults to 40%.
+		stakedCoins := s.totalSupply - s.spendableSupply
+		stakingLimit := float64(s.totalSupply) * s.stakingCap
+		stakingCapped := float64(stakedCoins) > stakingLimit
+
+		// Purchase tickets according to simulated demand curve or the
+		// decisions of the simulated stakeholders when there are any.
 		//
 		// When the height is prior to the stake validation height, just
-		// use a 50% demand rate to ramp up the simulation.
+		// use the configured ramp up demand rate, which defaults to 50%,
+		// to ramp up the simulation.  The tickets purchased to ramp up do
+		// not belong to any of the simulated stakeholders.
 		var newTickets uint8
+		var owners []uint32
 		if nextHeight < stakeValidationHeight {
 			if nextHeight >= ticketMaturity+1 {
-				newTickets = uint8(maxNewTicketsPerBlock / 2)
+				newTickets = uint8(float64(maxNewTicketsPerBlock) *
+					s.rampUpRate)
+			}
+			if s.agents != nil {
+				owners = make([]uint32, newTickets)
+				for i := range owners {
+					owners[i] = s.agents.bootstrapOwner()
+				}
+			}
+		} else if s.agents != nil {
+			nextTicketPrice := s.nextTicketPriceFunc()
+			maxTickets := int64(maxNewTicketsPerBlock)
+			maxPossible := int64(s.spendableSupply) / nextTicketPrice
+			if maxTickets > maxPossible {
+				maxTickets = maxPossible
 			}
+			if stakingCapped {
+				maxTickets = 0
+			}
+			owners = s.agents.purchaseTickets(s, nextHeight,
+				nextTicketPrice, int(maxTickets),
+				s.eventDemandFactor(nextHeight))
+			newTickets = uint8(len(owners))
 		} else {
+			// Calculate the demand at every retarget interval and
+			// also for the first block that is purchased according
+			// to the demand model since the default does not
+			// reflect the state of the simulation, such as when it
+			// continues from replayed CSV data part way through an
+			// interval.
 			nextTicketPrice := s.nextTicketPriceFunc()
-			if nextHeight%stakeDiffWindowSize == 0 {
-				demand := s.calcDemand(nextHeight, nextTicketPrice)
-				demandPerWindow = int32(float64(maxTicketsPerWindow) * demand)
+			if nextHeight%stakeDiffWindowSize == 0 || !s.demandComputed {
+				demand := s.demandModel.demand(s, nextHeight,
+					nextTicketPrice)
+				s.demandPerWindow = int32(float64(maxTicketsPerWindow) * demand)
+				s.demandComputed = true
 			}
 
-			newTickets = uint8(demandPerWindow / stakeDiffWindowSize)
+			// Scale the demand by any scripted demand spikes or
+			// drops.
+			wanted := float64(s.demandPerWindow/stakeDiffWindowSize) *
+				s.eventDemandFactor(nextHeight)
+			if wanted > float64(maxNewTicketsPerBlock) {
+				wanted = float64(maxNewTicketsPerBlock)
+			}
+			newTickets = uint8(wanted + 0.5)
 			maxPossible := int64(s.spendableSupply) / nextTicketPrice
 			if int64(newTickets) > maxPossible {
 				newTickets = uint8(maxPossible)
 			}
 		}
 
-		// TODO(davec): Account for tickets being purchased.
-		// Limit the total staked coins to 40% of the total supply.
-		stakedCoins := s.totalSupply - s.spendableSupply
-		if newTickets > 0 && stakedCoins > (s.totalSupply*4/10) {
+		if stakingCapped {
 			newTickets = 0
+			owners = nil
+		}
+
+		// A scripted whale purchases all of the remaining tickets that
+		// can be purchased in the block while it is active.  The whale
+		// brings in funds from outside of the staking population, so it
+		// is not limited by the staking cap.
+		if nextHeight >= stakeValidationHeight {
+			whaleTickets := s.eventWhaleTickets(nextHeight, newTickets)
+			if s.agents != nil {
+				for i := uint8(0); i < whaleTickets; i++ {
+					owners = append(owners, s.agents.whaleOwner())
+				}
+			}
+			newTickets += whaleTickets
 		}
 
-		// Start voting once stake validation height is reached.  This
-		// assumes no votes are missed and revokes all expired tickets
-		// as soon as possible which isn't very realistic, but it
-		// doesn't have any effect on the ticket prices, so it's good
-		// enough.  It could be useful to make this more realistic for
-		// other simulation purposes though.
+		// Start voting once stake validation height is reached.  The
+		// number of votes is determined by the configured missed vote
+		// model, which defaults to no missed votes, along with any
+		// scripted missvotes events.
 		var numVotes uint16
 		if nextHeight >= stakeValidationHeight {
-			numVotes = ticketsPerBlock
+			numVotes = s.voteModel.numVotes(nextHeight,
+				ticketsPerBlock, s.rng)
+			numVotes = s.eventVotes(nextHeight, numVotes)
+		}
+
+		// Revoke the missed and expired tickets that are due to be
+		// revoked according to the revocation model, which defaults to
+		// revoking them as soon as possible.  The header only allows
+		// for a limited number of revocations per block, so any others
+		// are delayed until the next block.
+		revocations := s.dueRevocations(nextHeight)
+		if revocations > math.MaxUint8 {
+			revocations = math.MaxUint8
+		}
+
+		// Stakeholders approve the previous block unless they randomly
+		// disapprove of it according to the configured probability.
+		// There are no votes to disapprove of blocks prior to the stake
+		// validation height.
+		prevValid := true
+		if numVotes > 0 && s.invalidationProb > 0 {
+			prevValid = s.rng.Float64() >= s.invalidationProb
 		}
+
 		data := &simData{
-			newTickets:  newTickets,
-			prevValid:   true,
-			revocations: uint16(len(s.unrevokedTickets)),
-			voters:      numVotes,
+			newTickets:   newTickets,
+			prevValid:    prevValid,
+			ticketOwners: owners,
+			revocations:  uint16(revocations),
+			voters:       numVotes,
 		}
 
 		// Create a new node that extends the current tip using the
 		// simulation data and potentially report the progress.
-		s.nextNode(data)
+		if _, err := s.nextNode(data); err != nil {
+			return err
+		}
 		s.reportProgress()
 	}
 
@@ -309,8 +430,355 @@ func main() {
 	var csvPath = flag.String("inputcsv", "",
 		"Path to simulation CSV input data -- This overrides numblocks")
 	var numBlocks = flag.Uint64("numblocks", 100000, "Number of blocks to simulate")
+	var algoName = flag.String("algo", defaultStakeDiffAlgorithm,
+		"Stake difficulty algorithm or schedule of algorithms, such "+
+			"as v1+dcp0001@5000, to use -- See -listalgos")
+	var listAlgos = flag.Bool("listalgos", false,
+		"List the available stake difficulty algorithms and exit")
+	var replayHeight = flag.Int("replayheight", 0, "Height to stop "+
+		"replaying the CSV input data at instead of using the entire "+
+		"file -- Requires inputcsv")
+	var continueBlocks = flag.Uint64("continue", 0, "Number of blocks "+
+		"to continue simulating with the demand model or stakeholders "+
+		"after replaying the CSV input data -- Requires inputcsv")
+	var replayAlgoName = flag.String("replayalgo", "mainnet", "Stake "+
+		"difficulty algorithm to use while replaying the CSV input "+
+		"data before continuing with algo -- Requires continue")
+	var lenient = flag.Bool("lenient", false, "Record anomalies in the "+
+		"CSV input data in a validation report and continue replaying "+
+		"where it is safe instead of stopping at the first one -- "+
+		"Requires inputcsv")
+	var verify = flag.Bool("verify", false,
+		"Verify the simulated ticket price, pool size, and lottery final "+
+			"state against the headers in the CSV input data -- "+
+			"Requires inputcsv")
+	var netName = flag.String("net", chaincfg.MainNetParams.Name,
+		"Network parameters to simulate (mainnet, testnet, or simnet)")
+	var paramsPath = flag.String("params", "",
+		"Path to a JSON file that overrides chain parameters of the "+
+			"selected network such as TicketPoolSize")
+	var voteModelCfg voteModelConfig
+	flag.StringVar(&voteModelCfg.Model, "votemodel", "perfect",
+		"Missed vote model for full simulations (perfect, fixed, "+
+			"random, or outage)")
+	flag.Float64Var(&voteModelCfg.MissRate, "missrate", 0.01,
+		"Fraction of selected tickets that miss their vote for the "+
+			"fixed, random, and outage vote models")
+	flag.Float64Var(&voteModelCfg.OutageProb, "outageprob", 0.001,
+		"Probability an outage starts in any given block for the "+
+			"outage vote model")
+	var outageLength = flag.Uint("outagelen", 144,
+		"Number of blocks an outage lasts for the outage vote model")
+	flag.Float64Var(&voteModelCfg.OutageMissRate, "outagemissrate", 0.2,
+		"Fraction of selected tickets that miss their vote during an "+
+			"outage for the outage vote model")
+	var revocationModelCfg revocationModelConfig
+	flag.StringVar(&revocationModelCfg.Model, "revokemodel", "immediate",
+		"Revocation delay model for full simulations (immediate, fixed, "+
+			"uniform, or exponential)")
+	var revokeDelay = flag.Uint("revokedelay", 144,
+		"Revocation delay in blocks -- Fixed delay for the fixed model, "+
+			"max delay for the uniform model, and mean delay for the "+
+			"exponential model")
+	flag.Float64Var(&revocationModelCfg.NeverFraction, "neverrevoke", 0,
+		"Fraction of missed and expired tickets that are never revoked")
+	var invalidProb = flag.Float64("invalidprob", 0,
+		"Probability stakeholders disapprove of the previous block in "+
+			"full simulations")
+	var demandName = flag.String("demand", defaultDemandModel,
+		"Demand model for full simulations -- See -listdemand")
+	var demandParamsSpec = flag.String("demandparams", "",
+		"Comma-separated list of name=value parameters for the demand "+
+			"model such as minyield=0.03,maxyield=0.06")
+	var listDemand = flag.Bool("listdemand", false,
+		"List the available demand models and their parameters and exit")
+	var agentsPath = flag.String("agents", "",
+		"Path to a JSON file describing a population of stakeholders "+
+			"that make their own purchase decisions in full "+
+			"simulations instead of the demand model -- Use "+
+			"\"default\" for a built-in population")
+	var eventsPath = flag.String("events", "",
+		"Path to a JSON file describing events scripted to occur at "+
+			"given heights in full simulations such as demand "+
+			"spikes")
+	var seed = flag.Int64("seed", 0, "Seed for all of the random number "+
+		"generators used by the simulation so a run can be reproduced "+
+		"-- Use 0 to seed from the current time")
+	var outDir = flag.String("out", "", "Directory to write the per-block "+
+		"results as CSV ("+blocksCSVFileName+") and a summary of "+
+		"the results as JSON ("+summaryJSONFileName+") to")
+	var noBrowser = flag.Bool("nobrowser", false, "Write the HTML "+
+		"results without attempting to open them in a browser")
+	var reportPath = flag.String("report", "", "Path to write the HTML "+
+		"results to (default: a timestamped file in the temp directory)")
+	var serveAddr = flag.String("serve", "", "Serve the HTML results "+
+		"over HTTP on the provided address, such as localhost:8080, "+
+		"instead of opening them in a browser")
+	var compareSpec = flag.String("compare", "", "Comma-separated list "+
+		"of stake difficulty algorithms to run the same simulation "+
+		"through and compare in a single report such as v1,dcp0001 "+
+		"-- This overrides algo")
+	var sweepSpec = flag.String("sweep", "", "Comma-separated list of "+
+		"chain parameter ranges of the form name=min:max[:step] to "+
+		"sweep such as StakeDiffAlpha=0:3,RetargetAdjustmentFactor=2:8:2")
+	var numSweepSamples = flag.Int("sweepsamples", 0, "Number of random "+
+		"points of the sweep ranges to simulate instead of the full "+
+		"grid -- Requires sweep")
+	var numRuns = flag.Int("runs", 1, "Number of independent full "+
+		"simulations with consecutive seeds to run and aggregate into "+
+		"percentile bands of the ticket price and pool size")
+	var parallel = flag.Int("parallel", runtime.NumCPU(), "Max number of "+
+		"simulations to run concurrently when runs is greater than one")
+	var checkpointPath = flag.String("checkpoint", "", "Path to write "+
+		"a checkpoint of the entire simulation state to once the "+
+		"simulation completes")
+	var resumePath = flag.String("resume", "", "Path to a checkpoint to "+
+		"resume a full simulation from -- The numblocks option is the "+
+		"number of additional blocks to simulate")
+	var rampUpRate = flag.Float64("rampup", defaultRampUpRate, "Fraction "+
+		"of the max new tickets per block purchased prior to stake "+
+		"validation height in full simulations")
+	var stakingCap = flag.Float64("stakingcap", defaultStakingCap, "Max "+
+		"fraction of the total supply that is staked in full simulations")
+	var scenarioPath = flag.String("scenario", "", "Path to a JSON file "+
+		"that describes the scenario to simulate -- Flags that are "+
+		"explicitly specified override the scenario")
 	flag.Parse()
 
+	// Load the scenario when one is specified and use it to set all of the
+	// flags that were not explicitly specified.
+	var scenarioParams *paramsOverrides
+	if *scenarioPath != "" {
+		sc, err := loadScenario(*scenarioPath)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		explicit := make(map[string]struct{})
+		flag.Visit(func(f *flag.Flag) {
+			explicit[f.Name] = struct{}{}
+		})
+		for name, value := range sc.flagValues() {
+			if _, ok := explicit[name]; ok {
+				continue
+			}
+			if err := flag.Set(name, value); err != nil {
+				fmt.Printf("invalid %s in scenario file %q: %v\n",
+					name, *scenarioPath, err)
+				return
+			}
+		}
+		scenarioParams = sc.Params
+	}
+
+	// Show the available stake difficulty algorithms and exit if requested.
+	if *listAlgos {
+		listStakeDiffAlgorithms(os.Stdout)
+		return
+	}
+	if *listDemand {
+		listDemandModels(os.Stdout)
+		return
+	}
+	algo, err := findStakeDiffAlgorithm(*algoName)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	var compareAlgos []*stakeDiffAlgorithm
+	if *compareSpec != "" {
+		compareAlgos, err = parseAlgorithmList(*compareSpec)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	var sweepParams []sweepParam
+	if *sweepSpec != "" {
+		if compareAlgos != nil {
+			fmt.Println("The sweep and compare options may not be " +
+				"used together")
+			return
+		}
+		sweepParams, err = parseSweepSpec(*sweepSpec)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	if *numSweepSamples < 0 || (*numSweepSamples > 0 && sweepParams == nil) {
+		fmt.Println("The sweepsamples option requires sweep and must " +
+			"not be negative")
+		return
+	}
+	if *numRuns < 1 || *parallel < 1 {
+		fmt.Println("The runs and parallel options must be at least one")
+		return
+	}
+	if (*replayHeight != 0 || *continueBlocks != 0) && *csvPath == "" {
+		fmt.Println("The replayheight and continue options require " +
+			"inputcsv")
+		return
+	}
+	if *replayHeight < 0 || *replayHeight > math.MaxInt32 {
+		fmt.Println("The replayheight option must be a valid height")
+		return
+	}
+	var replayAlgo *stakeDiffAlgorithm
+	if *continueBlocks > 0 {
+		replayAlgo, err = findStakeDiffAlgorithm(*replayAlgoName)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	// Multiple runs are only meaningful when there is a simulated portion
+	// that varies with the seed.
+	if *numRuns > 1 && ((*csvPath != "" && *continueBlocks == 0) ||
+		compareAlgos != nil || sweepParams != nil) {
+
+		fmt.Println("The runs option may not be used with the compare " +
+			"or sweep options or with inputcsv unless continue is " +
+			"specified")
+		return
+	}
+	if *verify && *csvPath == "" {
+		fmt.Println("The verify option requires inputcsv")
+		return
+	}
+	if *lenient && *csvPath == "" {
+		fmt.Println("The lenient option requires inputcsv")
+		return
+	}
+	demandInfo, err := findDemandModel(*demandName)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	demandParams, err := parseDemandParams(*demandParamsSpec)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	demandParams, err = demandInfo.resolveParams(demandParams)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	var agents []stakeholderConfig
+	if *agentsPath != "" {
+		agents, err = loadStakeholderConfigs(*agentsPath)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	var events []eventConfig
+	if *eventsPath != "" {
+		events, err = loadEventConfigs(*eventsPath)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	params, err := findNetworkParams(*netName)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if scenarioParams != nil {
+		params = scenarioParams.apply(params)
+		if err := validateParams(params); err != nil {
+			fmt.Printf("invalid params in scenario file %q: %v\n",
+				*scenarioPath, err)
+			return
+		}
+	}
+	if *paramsPath != "" {
+		params, err = loadParamsFile(params, *paramsPath)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	// Only a single simulation has a final state to write a checkpoint of.
+	if *checkpointPath != "" && (compareAlgos != nil ||
+		sweepParams != nil || *numRuns > 1) {
+
+		fmt.Println("The checkpoint option may not be used with the " +
+			"compare, sweep, or runs options")
+		return
+	}
+
+	// Load the checkpoint to resume from when requested.
+	var checkpoint *simCheckpoint
+	if *resumePath != "" {
+		if *csvPath != "" {
+			fmt.Println("The resume option may not be used with the " +
+				"inputcsv option")
+			return
+		}
+		checkpoint, err = loadCheckpoint(*resumePath)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		// Continue with the exact same randomness as the simulation
+		// the checkpoint was created from unless a seed is specified.
+		if *seed == 0 {
+			*seed = checkpoint.Seed
+		}
+	}
+
+	// Choose a seed based on the current time when one was not specified
+	// so that it can be shared by all of the simulations in a comparison.
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	voteModelCfg.OutageLength = uint32(*outageLength)
+	revocationModelCfg.Delay = uint32(*revokeDelay)
+	cfg := &simConfig{
+		params:           params,
+		algo:             algo,
+		replayAlgo:       replayAlgo,
+		seed:             *seed,
+		csvPath:          *csvPath,
+		replayHeight:     int32(*replayHeight),
+		continueBlocks:   *continueBlocks,
+		lenient:          *lenient,
+		numBlocks:        *numBlocks,
+		voteModel:        voteModelCfg,
+		revocationModel:  revocationModelCfg,
+		invalidationProb: *invalidProb,
+		rampUpRate:       *rampUpRate,
+		stakingCap:       *stakingCap,
+		demandInfo:       demandInfo,
+		demandParams:     demandParams,
+		agents:           agents,
+		events:           events,
+		checkpoint:       checkpoint,
+	}
+
+	// Ensure the configuration is valid before doing any work.
+	if _, err := cfg.newSimulator(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Exit with a failure status once everything else is done when the
+	// simulation does not match the CSV input data it was verified against.
+	// This is deferred before the CPU profile is started so the profile is
+	// still written.
+	var verifyFailed bool
+	defer func() {
+		if verifyFailed {
+			os.Exit(1)
+		}
+	}()
+
 	// Generate a CPU profile if requested.
 	if *cpuProfilePath != "" {
 		f, err := os.Create(*cpuProfilePath)
@@ -323,35 +791,217 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
-	// *********************************************************************
-	// NOTE: Set a different function to calculate the next required stake
-	// difficulty (aka ticket price) here.
-	// *********************************************************************
-	sim := newSimulator(&chaincfg.MainNetParams)
-	sim.nextTicketPriceFunc = sim.curCalcNextStakeDiff
+	resultsPath := *reportPath
+	if resultsPath == "" {
+		resultsPath = defaultResultsPath()
+	}
 
-	startTime := time.Now()
-	if *csvPath != "" {
-		fmt.Printf("Running simulation from %q.\n", *csvPath)
-		fmt.Printf("Height")
-		if err := sim.simulateFromCSV(*csvPath); err != nil {
+	// Run the scenario many times with different seeds across all of the
+	// cores and generate a single report with the spread of the outcomes
+	// when requested.
+	if *numRuns > 1 {
+		fmt.Printf("Running %d simulations %s using stake difficulty "+
+			"algorithm %q on %s with seeds %d to %d.\n", *numRuns,
+			cfg.describe(), algo.name, params.Name, cfg.seed,
+			cfg.seed+int64(*numRuns)-1)
+		fmt.Printf("Runs")
+		startTime := time.Now()
+		runs, err := cfg.runMonteCarlo(*numRuns, *parallel)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println("Simulations took", time.Since(startTime))
+		windowSize := int32(params.StakeDiffWindowSize)
+		results := aggregateMonteCarlo(runs, windowSize)
+		if *outDir != "" {
+			if err := results.exportResults(*outDir); err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Printf("Wrote results to %q.\n", *outDir)
+		}
+
+		err = generateMonteCarloResults(cfg, results, resultsPath)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := showResults(resultsPath, *serveAddr, *noBrowser); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	// Run the same scenario at every point of the parameter sweep and
+	// generate a single report ranking them when requested.
+	if sweepParams != nil {
+		var points [][]int64
+		if *numSweepSamples > 0 {
+			rng := rand.New(rand.NewSource(cfg.seed))
+			points, err = sweepSamples(sweepParams, *numSweepSamples, rng)
+		} else {
+			points, err = sweepGrid(sweepParams)
+		}
+		if err != nil {
 			fmt.Println(err)
 			return
 		}
-	} else {
-		fmt.Printf("Running simulation for %d blocks.\n", *numBlocks)
-		fmt.Printf("Height")
-		if err := sim.simulate(*numBlocks); err != nil {
+		fmt.Printf("Sweeping %d points using stake difficulty algorithm "+
+			"%q on %s with seed %d.\n", len(points), algo.name,
+			params.Name, cfg.seed)
+		fmt.Printf("Running each simulation %s.\n", cfg.describe())
+		startTime := time.Now()
+		runs := cfg.runSweep(sweepParams, points)
+		fmt.Println("Sweep took", time.Since(startTime))
+		if *outDir != "" {
+			if err := os.MkdirAll(*outDir, 0755); err != nil {
+				fmt.Printf("unable to create output directory: %v\n",
+					err)
+				return
+			}
+			path := filepath.Join(*outDir, sweepCSVFileName)
+			if err := writeSweepCSV(path, sweepParams, runs); err != nil {
+				fmt.Printf("unable to write %q: %v\n", path, err)
+				return
+			}
+			fmt.Printf("Wrote results to %q.\n", path)
+		}
+
+		err := generateSweepResults(cfg, sweepParams, runs, resultsPath)
+		if err != nil {
 			fmt.Println(err)
 			return
 		}
+		if err := showResults(resultsPath, *serveAddr, *noBrowser); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	// Run the same scenario through each of the algorithms and generate a
+	// single report comparing them when requested.
+	if compareAlgos != nil {
+		fmt.Printf("Comparing %d stake difficulty algorithms on %s with "+
+			"seed %d.\n", len(compareAlgos), params.Name, cfg.seed)
+		runs := make([]comparisonRun, 0, len(compareAlgos))
+		for _, algo := range compareAlgos {
+			cfg.algo = algo
+			sim, err := cfg.newSimulator()
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			var verifier *replayVerifier
+			if *verify {
+				verifier = newReplayVerifier()
+			}
+			startTime := time.Now()
+			fmt.Printf("Running simulation %s with algorithm %q.\n",
+				cfg.describe(), algo.name)
+			fmt.Printf("Height")
+			if err := cfg.run(sim, verifier); err != nil {
+				fmt.Println()
+				fmt.Println(err)
+				return
+			}
+			fmt.Println("..done")
+			fmt.Println("Simulation took", time.Since(startTime))
+			if verifier != nil {
+				verifier.writeReport(os.Stdout)
+				if verifier.failed() {
+					verifyFailed = true
+				}
+			}
+			if sim.validator != nil {
+				sim.validator.writeReport(os.Stdout)
+			}
+			if *outDir != "" {
+				dir := filepath.Join(*outDir, algo.name)
+				if err := sim.exportResults(dir); err != nil {
+					fmt.Println(err)
+					return
+				}
+				fmt.Printf("Wrote results to %q.\n", dir)
+			}
+			runs = append(runs, comparisonRun{
+				algo:    algo,
+				sim:     sim,
+				summary: sim.summarizeResults(),
+			})
+		}
+
+		err := generateComparisonResults(runs, resultsPath)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := showResults(resultsPath, *serveAddr, *noBrowser); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	// Create the simulator using the selected function to calculate the
+	// next required stake difficulty (aka ticket price).
+	sim, err := cfg.newSimulator()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	var verifier *replayVerifier
+	if *verify {
+		verifier = newReplayVerifier()
+	}
+	startTime := time.Now()
+	fmt.Printf("Using stake difficulty algorithm %q on %s with seed "+
+		"%d.\n", algo.name, params.Name, sim.seed)
+	fmt.Printf("Running simulation %s.\n", cfg.describe())
+	fmt.Printf("Height")
+	if err := cfg.run(sim, verifier); err != nil {
+		fmt.Println()
+		fmt.Println(err)
+		return
 	}
 	fmt.Println("..done")
 	fmt.Println("Simulation took", time.Since(startTime))
+	if verifier != nil {
+		verifier.writeReport(os.Stdout)
+		verifyFailed = verifier.failed()
+	}
+	if sim.validator != nil {
+		sim.validator.writeReport(os.Stdout)
+	}
+
+	// Write a checkpoint of the final state of the simulation so it can be
+	// resumed later when requested.
+	if *checkpointPath != "" {
+		if err := sim.writeCheckpoint(*checkpointPath); err != nil {
+			fmt.Printf("unable to write checkpoint %q: %v\n",
+				*checkpointPath, err)
+			return
+		}
+		fmt.Printf("Wrote checkpoint to %q.\n", *checkpointPath)
+	}
 
-	// Generate the simulation results and open them in a browser.
-	if err := generateResults(sim); err != nil {
+	// Write the machine-readable results when requested.
+	if *outDir != "" {
+		if err := sim.exportResults(*outDir); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("Wrote results to %q.\n", *outDir)
+	}
+
+	// Generate the simulation results and either serve them, open them
+	// in a browser, or just report where they were written depending on
+	// the options.
+	if err := generateResults(sim, resultsPath); err != nil {
 		fmt.Println(err)
 		return
 	}
+	if err := showResults(resultsPath, *serveAddr, *noBrowser); err != nil {
+		fmt.Println(err)
+	}
 }
@@ -0,0 +1,73 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/decred/dcrd/chaincfg"
+)
+
+// TestSimulateDemandAfterReplay ensures a full simulation that continues from
+// replayed data part way through a ticket price window purchases tickets
+// according to the demand model starting with its very first block instead of
+// the default demand.
+func TestSimulateDemandAfterReplay(t *testing.T) {
+	params := chaincfg.MainNetParams
+	s := newSimulator(&params)
+	s.setSeed(1)
+	algo, err := findStakeDiffAlgorithm("v1")
+	if err != nil {
+		t.Fatalf("unable to find algorithm: %v", err)
+	}
+	s.setStakeDiffAlgorithm(algo)
+	info, err := findDemandModel("constant")
+	if err != nil {
+		t.Fatalf("unable to find demand model: %v", err)
+	}
+	s.demandModel, err = info.newDemandModel(map[string]float64{
+		"level": 0.3,
+	})
+	if err != nil {
+		t.Fatalf("unable to create demand model: %v", err)
+	}
+	s.stakingCap = 1
+
+	// Replay data up to a height that is not at a window boundary in the
+	// same way a hybrid simulation does.
+	const replayHeight = 5000
+	windowSize := int32(params.StakeDiffWindowSize)
+	if (replayHeight+1)%windowSize == 0 {
+		t.Fatalf("replay height %d must not end a window", replayHeight)
+	}
+	for height := int32(0); height <= replayHeight; height++ {
+		data := &simData{prevValid: true}
+		if height > int32(params.CoinbaseMaturity) {
+			data.newTickets = 6
+		}
+		if int64(height) >= params.StakeValidationHeight {
+			data.voters = params.TicketsPerBlock
+		}
+		if _, err := s.nextNode(data); err != nil {
+			t.Fatalf("unable to replay height %d: %v", height, err)
+		}
+	}
+
+	// Continue with the demand model and ensure every block purchases the
+	// number of tickets it calls for.
+	s.quiet = true
+	const numBlocks = 100
+	if err := s.simulate(numBlocks); err != nil {
+		t.Fatalf("unable to simulate: %v", err)
+	}
+	maxPerWindow := float64(params.MaxFreshStakePerBlock) * float64(windowSize)
+	want := int(maxPerWindow * 0.3 / float64(windowSize))
+	for node := s.tip; node.height > replayHeight; node = node.parent {
+		if got := len(node.ticketsAdded); got != want {
+			t.Errorf("height %d: purchased %d tickets, want %d",
+				node.height, got, want)
+		}
+	}
+}
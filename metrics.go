@@ -0,0 +1,253 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// convergenceTolerance is the maximum deviation of the pool size from the
+// target, as a fraction of the target, for the pool size to be considered
+// converged.  It is also used as the hysteresis band when detecting pool size
+// oscillations so noise around the target is not counted.
+const convergenceTolerance = 0.05
+
+// stabilityMetrics houses the quality metrics used to rank ticket price
+// algorithms.  Only blocks at or after the stake validation height are
+// considered since the ticket pool is still being bootstrapped prior to that.
+type stabilityMetrics struct {
+	// NumWindows is the number of ticket price windows considered.
+	NumWindows uint32 `json:"numwindows"`
+
+	// PriceVolatility is the standard deviation of the natural log of the
+	// change in the ticket price from one window to the next.
+	PriceVolatility float64 `json:"pricevolatility"`
+
+	// ClampedWindows is the number of windows at which the ticket price
+	// changed by exactly the maximum retarget adjustment factor, which
+	// means it was limited by the clamp.
+	ClampedWindows uint32 `json:"clampedwindows"`
+
+	// PoolSizeTarget is the target pool size, which is the number of
+	// tickets per block multiplied by the ticket pool size.
+	PoolSizeTarget uint32 `json:"poolsizetarget"`
+
+	// PoolSizeMAD is the mean absolute deviation of the pool size from the
+	// target.
+	PoolSizeMAD float64 `json:"poolsizemad"`
+
+	// OscillationPeriod is the mean number of blocks between consecutive
+	// times the pool size rises above the target after having fallen below
+	// it.  It is zero when the pool size does not oscillate.
+	OscillationPeriod float64 `json:"oscillationperiod"`
+
+	// NumShocks is the number of shocks the convergence time is measured
+	// after.  The start of voting at the stake validation height is always
+	// treated as a shock along with the start of every scripted event after
+	// it.
+	NumShocks int `json:"numshocks"`
+
+	// ConvergenceTime is the mean number of blocks it took the pool size
+	// to converge to within the tolerance of the target and remain there
+	// for a full window after each shock that it converged after.
+	ConvergenceTime float64 `json:"convergencetime"`
+
+	// NumUnconverged is the number of shocks the pool size never converged
+	// after before the next shock or the end of the simulation.
+	NumUnconverged int `json:"numunconverged"`
+}
+
+// isClamped returns whether or not the change from the old ticket price to the
+// new one is exactly the maximum allowed by the retarget adjustment factor.
+func isClamped(oldPrice, newPrice, maxRetarget int64) bool {
+	if oldPrice == newPrice {
+		return false
+	}
+	return newPrice == oldPrice*maxRetarget ||
+		newPrice == oldPrice/maxRetarget
+}
+
+// convergenceTime returns the number of blocks from the start of the passed
+// pool sizes until they are within the tolerance of the target and remain
+// there for the provided number of blocks along with whether or not they
+// converged at all.
+func convergenceTime(poolSizes []uint32, target float64, holdBlocks int) (int, bool) {
+	tolerance := target * convergenceTolerance
+	var inBand int
+	for i, poolSize := range poolSizes {
+		if math.Abs(float64(poolSize)-target) > tolerance {
+			inBand = 0
+			continue
+		}
+		inBand++
+		if inBand >= holdBlocks {
+			return i + 1 - inBand, true
+		}
+	}
+	return 0, false
+}
+
+// calcStabilityMetrics returns the stability and quality metrics of the
+// simulation.
+func (s *simulator) calcStabilityMetrics() stabilityMetrics {
+	// Shorter versions of some params for convenience.
+	stakeValidationHeight := int32(s.params.StakeValidationHeight)
+	windowSize := int32(s.params.StakeDiffWindowSize)
+	maxRetarget := s.params.RetargetAdjustmentFactor
+	target := uint32(s.params.TicketsPerBlock) *
+		uint32(s.params.TicketPoolSize)
+
+	// Collect the pool sizes and the ticket prices of each window after
+	// the stake validation height.
+	var poolSizes []uint32
+	var windowPrices []int64
+	for node := s.root; node != nil; node = node.next {
+		if node.height < stakeValidationHeight {
+			continue
+		}
+		poolSizes = append(poolSizes, node.poolSize)
+		if node.height%windowSize == 0 {
+			windowPrices = append(windowPrices, node.ticketPrice)
+		}
+	}
+	metrics := stabilityMetrics{
+		NumWindows:     uint32(len(windowPrices)),
+		PoolSizeTarget: target,
+	}
+	if len(poolSizes) == 0 {
+		return metrics
+	}
+
+	// Calculate the volatility of the ticket price from the log returns
+	// of each window and count the windows that were clamped.
+	var logReturns []float64
+	for i := 1; i < len(windowPrices); i++ {
+		oldPrice, newPrice := windowPrices[i-1], windowPrices[i]
+		if isClamped(oldPrice, newPrice, maxRetarget) {
+			metrics.ClampedWindows++
+		}
+		if oldPrice > 0 && newPrice > 0 {
+			logReturn := math.Log(float64(newPrice) / float64(oldPrice))
+			logReturns = append(logReturns, logReturn)
+		}
+	}
+	if len(logReturns) > 0 {
+		var sum, sumSquares float64
+		for _, logReturn := range logReturns {
+			sum += logReturn
+		}
+		mean := sum / float64(len(logReturns))
+		for _, logReturn := range logReturns {
+			sumSquares += (logReturn - mean) * (logReturn - mean)
+		}
+		variance := sumSquares / float64(len(logReturns))
+		metrics.PriceVolatility = math.Sqrt(variance)
+	}
+
+	// Calculate the mean absolute deviation of the pool size from the
+	// target and the oscillation period.  A rise above the target is only
+	// counted once the pool size has fallen below the tolerance band so
+	// that noise around the target is ignored.
+	fTarget := float64(target)
+	tolerance := fTarget * convergenceTolerance
+	var sumDeviation float64
+	var crossings []int
+	var below bool
+	for i, poolSize := range poolSizes {
+		deviation := float64(poolSize) - fTarget
+		sumDeviation += math.Abs(deviation)
+		switch {
+		case deviation < -tolerance:
+			below = true
+		case deviation > tolerance && below:
+			below = false
+			crossings = append(crossings, i)
+		}
+	}
+	metrics.PoolSizeMAD = sumDeviation / float64(len(poolSizes))
+	if len(crossings) > 1 {
+		span := crossings[len(crossings)-1] - crossings[0]
+		metrics.OscillationPeriod = float64(span) /
+			float64(len(crossings)-1)
+	}
+
+	// Calculate the time it took the pool size to converge after each
+	// shock, which are the start of voting and any scripted events after
+	// it.  Each shock is only measured until the next one.
+	shocks := []int32{stakeValidationHeight}
+	for _, height := range s.events.shockHeights() {
+		if height > stakeValidationHeight {
+			shocks = append(shocks, height)
+		}
+	}
+	var sumConvergence, numConverged int
+	for i, shockHeight := range shocks {
+		start := int(shockHeight - stakeValidationHeight)
+		end := len(poolSizes)
+		if i+1 < len(shocks) {
+			end = int(shocks[i+1] - stakeValidationHeight)
+		}
+		if start < 0 || start >= end || end > len(poolSizes) {
+			continue
+		}
+		metrics.NumShocks++
+		blocks, converged := convergenceTime(poolSizes[start:end],
+			fTarget, int(windowSize))
+		if !converged {
+			metrics.NumUnconverged++
+			continue
+		}
+		sumConvergence += blocks
+		numConverged++
+	}
+	if numConverged > 0 {
+		metrics.ConvergenceTime = float64(sumConvergence) /
+			float64(numConverged)
+	}
+
+	return metrics
+}
+
+// formattedMetric is the name and human-readable value of a metric shown in
+// the simulation results.
+type formattedMetric struct {
+	Name  string
+	Value string
+}
+
+// formatted returns the metrics formatted for display in the simulation
+// results in the order they are shown.
+func (m *stabilityMetrics) formatted() []formattedMetric {
+	formatFloat := func(f float64, prec int) string {
+		return strconv.FormatFloat(f, 'f', prec, 64)
+	}
+	var madPercent float64
+	if m.PoolSizeTarget > 0 {
+		madPercent = m.PoolSizeMAD / float64(m.PoolSizeTarget) * 100
+	}
+	oscillation := "none"
+	if m.OscillationPeriod > 0 {
+		oscillation = formatFloat(m.OscillationPeriod, 0) + " blocks"
+	}
+	convergence := "never"
+	if m.NumShocks > m.NumUnconverged {
+		convergence = formatFloat(m.ConvergenceTime, 0) + " blocks"
+	}
+	return []formattedMetric{
+		{"Ticket Price Volatility Per Window",
+			formatFloat(m.PriceVolatility*100, 2) + "%"},
+		{"Windows Clamped by Retarget Limit",
+			fmt.Sprintf("%d of %d", m.ClampedWindows, m.NumWindows)},
+		{"Pool Size Target", strconv.FormatUint(uint64(m.PoolSizeTarget), 10)},
+		{"Pool Size Mean Absolute Deviation", fmt.Sprintf("%s (%s%%)",
+			formatFloat(m.PoolSizeMAD, 2), formatFloat(madPercent, 2))},
+		{"Pool Size Oscillation Period", oscillation},
+		{"Mean Convergence Time After Shocks", fmt.Sprintf("%s (%d of %d "+
+			"shocks unconverged)", convergence, m.NumUnconverged,
+			m.NumShocks)},
+	}
+}
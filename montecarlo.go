@@ -0,0 +1,462 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/decred/dcrutil"
+)
+
+const (
+	// bandsCSVFileName is the name of the file the per-height percentiles
+	// of a Monte Carlo simulation are written to in the output directory.
+	bandsCSVFileName = "bands.csv"
+
+	// runsCSVFileName is the name of the file the stability metrics of
+	// every run of a Monte Carlo simulation are written to in the output
+	// directory.
+	runsCSVFileName = "runs.csv"
+)
+
+// monteCarloPercentiles are the percentiles of the per-height distributions
+// reported by a Monte Carlo simulation.  The outer pairs are shown as bands
+// around the median in the results.
+var monteCarloPercentiles = []float64{5, 25, 50, 75, 95}
+
+// monteCarloRun houses the per-height time series and summary of a single run
+// of a Monte Carlo simulation.  Only the data needed for the aggregate results
+// is kept so that the memory used by the simulator can be released as soon as
+// the run completes.
+type monteCarloRun struct {
+	seed         int64
+	algoSchedule stakeDiffSchedule
+	poolSizes    []uint32
+	ticketPrices []int64
+	summary      *resultsSummary
+}
+
+// runMonteCarlo runs the simulation described by the configuration the provided
+// number of times with consecutive seeds starting with the configured seed and
+// returns the results in order of their seeds.  Up to the passed number of runs
+// are executed concurrently.
+//
+// Each run is completely independent since all of its state, including the
+// random number generator, is owned by its simulator.  The ticket treap also
+// draws the priorities of its nodes from a package-level generator which is
+// shared by the runs, however, it is safe for concurrent access and the shape
+// of the treap does not affect the results.
+func (cfg *simConfig) runMonteCarlo(numRuns, parallel int) ([]monteCarloRun, error) {
+	windowSize := int32(cfg.params.StakeDiffWindowSize)
+	runs := make([]monteCarloRun, numRuns)
+	errs := make([]error, numRuns)
+	indices := make(chan int)
+	var completed int
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	for worker := 0; worker < parallel; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				runCfg := *cfg
+				runCfg.seed = cfg.seed + int64(i)
+				sim, err := runCfg.newSimulator()
+				if err == nil {
+					sim.quiet = true
+					err = runCfg.run(sim, nil)
+				}
+				if err != nil {
+					errs[i] = err
+					continue
+				}
+
+				run := monteCarloRun{
+					seed:         runCfg.seed,
+					algoSchedule: sim.algoSchedule,
+					summary:      sim.summarizeResults(),
+				}
+				for node := sim.root; node != nil; node = node.next {
+					run.poolSizes = append(run.poolSizes,
+						node.poolSize)
+					if node.height%windowSize == 0 {
+						run.ticketPrices = append(
+							run.ticketPrices,
+							node.ticketPrice)
+					}
+				}
+				runs[i] = run
+
+				mtx.Lock()
+				completed++
+				fmt.Printf("..%d", completed)
+				if completed%20 == 0 {
+					fmt.Println()
+				}
+				mtx.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < numRuns; i++ {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+	fmt.Println("..done")
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("run with seed %d failed: %v",
+				cfg.seed+int64(i), err)
+		}
+	}
+	return runs, nil
+}
+
+// percentile returns the provided percentile of the passed values, which must
+// already be sorted in ascending order, by linearly interpolating between the
+// closest ranks.
+func percentile(sorted []float64, pct float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := pct / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	frac := rank - float64(lower)
+	return sorted[lower] + (sorted[upper]-sorted[lower])*frac
+}
+
+// percentiles returns the Monte Carlo percentiles of the passed values.  The
+// values are sorted in place.
+func percentiles(values []float64) []float64 {
+	sort.Float64s(values)
+	results := make([]float64, 0, len(monteCarloPercentiles))
+	for _, pct := range monteCarloPercentiles {
+		results = append(results, percentile(values, pct))
+	}
+	return results
+}
+
+// monteCarloBand houses the percentiles of the distribution of a value across
+// all runs of a Monte Carlo simulation at a given height.
+type monteCarloBand struct {
+	height      int32
+	percentiles []float64
+}
+
+// aggregateBands returns the percentiles of the values returned by the passed
+// function for each index of the per-height series of the runs.  Runs that are
+// shorter than others only contribute to the heights they reached.
+func aggregateBands(runs []monteCarloRun, heights func(i int) int32, length func(*monteCarloRun) int, value func(*monteCarloRun, int) float64) []monteCarloBand {
+	var maxLen int
+	for i := range runs {
+		if n := length(&runs[i]); n > maxLen {
+			maxLen = n
+		}
+	}
+	bands := make([]monteCarloBand, 0, maxLen)
+	values := make([]float64, 0, len(runs))
+	for idx := 0; idx < maxLen; idx++ {
+		values = values[:0]
+		for i := range runs {
+			if idx < length(&runs[i]) {
+				values = append(values, value(&runs[i], idx))
+			}
+		}
+		bands = append(bands, monteCarloBand{
+			height:      heights(idx),
+			percentiles: percentiles(values),
+		})
+	}
+	return bands
+}
+
+// monteCarloResults houses the aggregate results of a Monte Carlo simulation.
+type monteCarloResults struct {
+	runs         []monteCarloRun
+	poolSizes    []monteCarloBand
+	ticketPrices []monteCarloBand
+}
+
+// aggregateMonteCarlo returns the per-height distributions of the pool size
+// and ticket price across all of the passed runs.
+func aggregateMonteCarlo(runs []monteCarloRun, windowSize int32) *monteCarloResults {
+	poolSizes := aggregateBands(runs, func(i int) int32 {
+		return int32(i)
+	}, func(run *monteCarloRun) int {
+		return len(run.poolSizes)
+	}, func(run *monteCarloRun, i int) float64 {
+		return float64(run.poolSizes[i])
+	})
+	ticketPrices := aggregateBands(runs, func(i int) int32 {
+		return int32(i) * windowSize
+	}, func(run *monteCarloRun) int {
+		return len(run.ticketPrices)
+	}, func(run *monteCarloRun, i int) float64 {
+		return dcrutil.Amount(run.ticketPrices[i]).ToCoin()
+	})
+	return &monteCarloResults{
+		runs:         runs,
+		poolSizes:    poolSizes,
+		ticketPrices: ticketPrices,
+	}
+}
+
+// writeBandsCSV writes the per-height percentiles of the pool size and ticket
+// price to the file at the provided path.  The ticket price percentiles are
+// only available at the heights at which the ticket price changes, so they
+// are left empty for all other heights.  Ticket prices are in DCR since they
+// are no longer exact amounts once interpolated.
+func (r *monteCarloResults) writeBandsCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := []string{"height"}
+	for _, prefix := range []string{"poolsize", "ticketprice"} {
+		for _, pct := range monteCarloPercentiles {
+			header = append(header, fmt.Sprintf("%s_p%v", prefix, pct))
+		}
+	}
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	var priceIdx int
+	for _, band := range r.poolSizes {
+		record := []string{strconv.Itoa(int(band.height))}
+		for _, value := range band.percentiles {
+			record = append(record, formatFloat(value))
+		}
+		if priceIdx < len(r.ticketPrices) &&
+			r.ticketPrices[priceIdx].height == band.height {
+
+			for _, value := range r.ticketPrices[priceIdx].percentiles {
+				record = append(record, formatFloat(value))
+			}
+			priceIdx++
+		} else {
+			for range monteCarloPercentiles {
+				record = append(record, "")
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// writeRunsCSV writes the seed and stability metrics of every run to the file
+// at the provided path so any run of interest can be reproduced with its seed.
+func (r *monteCarloResults) writeRunsCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	err = w.Write([]string{"seed", "pricevolatility", "clampedwindows",
+		"poolsizemad", "oscillationperiod", "convergencetime",
+		"numunconverged", "minpoolsize", "maxpoolsize", "minticketprice",
+		"maxticketprice"})
+	if err != nil {
+		return err
+	}
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	for _, run := range r.runs {
+		m := &run.summary.Stability
+		err := w.Write([]string{
+			strconv.FormatInt(run.seed, 10),
+			formatFloat(m.PriceVolatility),
+			strconv.FormatUint(uint64(m.ClampedWindows), 10),
+			formatFloat(m.PoolSizeMAD),
+			formatFloat(m.OscillationPeriod),
+			formatFloat(m.ConvergenceTime),
+			strconv.Itoa(m.NumUnconverged),
+			strconv.FormatUint(uint64(run.summary.MinPoolSize), 10),
+			strconv.FormatUint(uint64(run.summary.MaxPoolSize), 10),
+			strconv.FormatInt(int64(run.summary.MinTicketPrice), 10),
+			strconv.FormatInt(int64(run.summary.MaxTicketPrice), 10),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// exportResults writes the per-height percentiles and the per-run metrics of
+// the Monte Carlo simulation as machine-readable files in the provided
+// directory.  The directory is created if it does not already exist.
+func (r *monteCarloResults) exportResults(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("unable to create output directory: %v", err)
+	}
+	bandsPath := filepath.Join(dir, bandsCSVFileName)
+	if err := r.writeBandsCSV(bandsPath); err != nil {
+		return fmt.Errorf("unable to write %q: %v", bandsPath, err)
+	}
+	runsPath := filepath.Join(dir, runsCSVFileName)
+	if err := r.writeRunsCSV(runsPath); err != nil {
+		return fmt.Errorf("unable to write %q: %v", runsPath, err)
+	}
+	return nil
+}
+
+// bandsCSV returns the passed bands as CSV data suitable for a chart with
+// custom error bars.  Each row contains the median surrounded by the outer and
+// inner percentiles as separate series.
+func bandsCSV(bands []monteCarloBand, prec int) string {
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', prec, 64)
+	}
+	var csv bytes.Buffer
+	for _, band := range bands {
+		p := band.percentiles
+		csv.WriteString(strconv.Itoa(int(band.height)))
+		fmt.Fprintf(&csv, ",%s;%s;%s,%s;%s;%s\n", formatFloat(p[0]),
+			formatFloat(p[2]), formatFloat(p[4]), formatFloat(p[1]),
+			formatFloat(p[2]), formatFloat(p[3]))
+	}
+	return csv.String()
+}
+
+// metricDistribution is a single row of the metrics table in the Monte Carlo
+// results which holds the percentiles of a metric across all runs.
+type metricDistribution struct {
+	Name   string
+	Values []string
+}
+
+// metricDistributions returns the percentiles of the stability metrics across
+// all of the runs shown in the Monte Carlo results.
+func (r *monteCarloResults) metricDistributions() []metricDistribution {
+	metric := func(name string, prec int, value func(*resultsSummary) float64) metricDistribution {
+		values := make([]float64, 0, len(r.runs))
+		for _, run := range r.runs {
+			values = append(values, value(run.summary))
+		}
+		formatted := make([]string, 0, len(monteCarloPercentiles))
+		for _, pct := range percentiles(values) {
+			formatted = append(formatted,
+				strconv.FormatFloat(pct, 'f', prec, 64))
+		}
+		return metricDistribution{Name: name, Values: formatted}
+	}
+	return []metricDistribution{
+		metric("Ticket Price Volatility Per Window (%)", 2,
+			func(s *resultsSummary) float64 {
+				return s.Stability.PriceVolatility * 100
+			}),
+		metric("Windows Clamped by Retarget Limit", 0,
+			func(s *resultsSummary) float64 {
+				return float64(s.Stability.ClampedWindows)
+			}),
+		metric("Pool Size Mean Absolute Deviation", 2,
+			func(s *resultsSummary) float64 {
+				return s.Stability.PoolSizeMAD
+			}),
+		metric("Pool Size Oscillation Period (blocks)", 0,
+			func(s *resultsSummary) float64 {
+				return s.Stability.OscillationPeriod
+			}),
+		metric("Mean Convergence Time After Shocks (blocks)", 0,
+			func(s *resultsSummary) float64 {
+				return s.Stability.ConvergenceTime
+			}),
+		metric("Min Pool Size", 0, func(s *resultsSummary) float64 {
+			return float64(s.MinPoolSize)
+		}),
+		metric("Max Pool Size", 0, func(s *resultsSummary) float64 {
+			return float64(s.MaxPoolSize)
+		}),
+		metric("Min Ticket Price (DCR)", 8, func(s *resultsSummary) float64 {
+			return s.MinTicketPrice.ToCoin()
+		}),
+		metric("Max Ticket Price (DCR)", 8, func(s *resultsSummary) float64 {
+			return s.MaxTicketPrice.ToCoin()
+		}),
+	}
+}
+
+// generateMonteCarloResults creates an HTML results file at the provided path
+// that shows the spread of the outcomes of a Monte Carlo simulation with
+// percentile bands around the median ticket price and pool size along with the
+// distributions of the stability metrics.
+func generateMonteCarloResults(cfg *simConfig, results *monteCarloResults, resultsPath string) error {
+	// Parse the Monte Carlo template along with the results template which
+	// defines the shared portions.
+	tpl, err := template.New("results").Parse(resultsTmplText)
+	if err != nil {
+		return fmt.Errorf("unable to parse results template: %v", err)
+	}
+	tpl, err = tpl.New("montecarlo").Parse(monteCarloTmplText)
+	if err != nil {
+		return fmt.Errorf("unable to parse Monte Carlo template: %v", err)
+	}
+	resultsFile, err := os.Create(resultsPath)
+	if err != nil {
+		return fmt.Errorf("unable to create results: %v", err)
+	}
+	defer resultsFile.Close()
+
+	pctLabels := make([]string, 0, len(monteCarloPercentiles))
+	for _, pct := range monteCarloPercentiles {
+		pctLabels = append(pctLabels, fmt.Sprintf("P%v", pct))
+	}
+	p := monteCarloPercentiles
+	labels := []string{"Block",
+		fmt.Sprintf("Median (P%v-P%v)", p[0], p[4]),
+		fmt.Sprintf("Median (P%v-P%v)", p[1], p[3])}
+	lastSeed := cfg.seed + int64(len(results.runs)) - 1
+
+	// All of the runs use the same algorithms at the same heights.
+	algoSchedule := results.runs[0].algoSchedule
+	err = tpl.Execute(resultsFile, map[string]interface{}{
+		"Algorithm":        algoSchedule.String(),
+		"Activations":      algoSchedule.activationHeights(),
+		"Network":          cfg.params.Name,
+		"Description":      cfg.describe(),
+		"NumRuns":          len(results.runs),
+		"Seeds":            fmt.Sprintf("%d to %d", cfg.seed, lastSeed),
+		"PercentileLabels": pctLabels,
+		"Metrics":          results.metricDistributions(),
+		"Labels":           labels,
+		"PoolSizeCSV":      bandsCSV(results.poolSizes, 2),
+		"TicketPriceCSV":   bandsCSV(results.ticketPrices, 8),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to execute template: %v", err)
+	}
+
+	return resultsFile.Close()
+}
@@ -0,0 +1,107 @@
+package main
+
+var monteCarloTmplText = `
+<!doctype html>
+<html lang="en">
+  <head>
+    <meta charset="utf-8">
+    <meta http-equiv="X-UA-Compatible" content="IE=edge">
+    <title>Monte Carlo Simulation Results</title>
+    {{template "libs"}}
+  </head>
+  <body style="margin: 0px; padding: 0px;">
+    <div id="container" style="width: 100%;">
+      {{template "navbar"}}
+      <div style="width: 95%;">
+        <table>
+          <tr>
+            <th>Stake Difficulty Algorithm</th>
+            <th colspan="{{len .PercentileLabels}}">{{.Algorithm}}</th>
+          </tr>
+          <tr>
+            <th>Network</th>
+            <th colspan="{{len .PercentileLabels}}">{{.Network}}</th>
+          </tr>
+          <tr>
+            <th>Simulation</th>
+            <th colspan="{{len .PercentileLabels}}">{{.Description}}</th>
+          </tr>
+          <tr>
+            <th>Number of Runs</th>
+            <th colspan="{{len .PercentileLabels}}">{{.NumRuns}}</th>
+          </tr>
+          <tr>
+            <th>Random Seeds</th>
+            <th colspan="{{len .PercentileLabels}}">{{.Seeds}}</th>
+          </tr>
+          <tr>
+            <th>Metric</th>
+            {{range .PercentileLabels}}
+            <th>{{.}}</th>
+            {{end}}
+          </tr>
+          {{range .Metrics}}
+          <tr>
+            <td>{{.Name}}</td>
+            {{range .Values}}
+            <td>{{.}}</td>
+            {{end}}
+          </tr>
+          {{end}}
+        </table>
+      </div>
+      <div id="charts" style="width: 95%; text-align: center;">
+        <div id="poolsizediv" style="width: 50%; float: left;"></div>
+        <div id="ticketpricediv" style="width: 50%; float: right;"></div>
+      </div>
+    </div>
+
+    <script>
+      // Mark the heights at which the stake difficulty algorithm changes.
+      var activations = {{.Activations}};
+      var markActivations = function(canvas, area, g) {
+        canvas.fillStyle = '#ed6d47';
+        activations.forEach(function(height) {
+          canvas.fillRect(g.toDomXCoord(height), area.y, 2, area.h);
+        });
+      };
+
+      window.onload = function() {
+        var csv = "{{.PoolSizeCSV}}";
+        var poolSizeGraph = new Dygraph(document.getElementById("poolsizediv"), csv,
+          {
+            title: 'Pool Size Per Block',
+            labels: {{.Labels}},
+            xlabel: 'Block Height',
+            ylabel: 'Pool Size',
+            legend: 'always',
+            customBars: true,
+            underlayCallback: markActivations,
+            animatedZooms: true,
+            plugins : [
+                Dygraph.Plugins.Unzoom
+            ]
+          }
+        );
+
+        var csv = "{{.TicketPriceCSV}}";
+        var ticketPriceGraph = new Dygraph(document.getElementById("ticketpricediv"), csv,
+          {
+            title: 'Ticket Price Per Retarget Interval',
+            labels: {{.Labels}},
+            xlabel: 'Block Height',
+            ylabel: 'Ticket Price',
+            legend: 'always',
+            customBars: true,
+            underlayCallback: markActivations,
+            animatedZooms: true,
+            plugins : [
+                Dygraph.Plugins.Unzoom
+            ]
+          }
+        );
+      }
+    </script>
+  </body>
+</html>
+`
@@ -0,0 +1,259 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/decred/dcrutil"
+)
+
+// ownerStats houses the ticket and reward accounting for a single owner of
+// tickets.
+type ownerStats struct {
+	purchased   uint32
+	liveTickets uint32
+	invested    dcrutil.Amount
+	voteRewards dcrutil.Amount
+	returned    dcrutil.Amount
+	refunds     dcrutil.Amount
+	locked      dcrutil.Amount
+	abandoned   dcrutil.Amount
+}
+
+// roi returns the return on investment of the owner as a fraction of the total
+// amount spent on tickets.  Funds that are still locked in tickets are treated
+// as returned since the ticket price is eventually refunded, however, the funds
+// in missed or expired tickets that are never revoked are lost.
+func (o *ownerStats) roi() float64 {
+	if o.invested == 0 {
+		return 0
+	}
+	value := o.voteRewards + o.returned + o.refunds + o.locked
+	return float64(value-o.invested) / float64(o.invested)
+}
+
+// poolShareSample is the fraction of the live ticket pool held by each owner
+// as of a given height.
+type poolShareSample struct {
+	height int32
+	shares []float64
+}
+
+// ownershipLedger tracks which owner purchased each ticket along with the vote
+// rewards, revocation refunds, and locked funds of every owner so the returns
+// of individual stakeholders can be reported.
+//
+// Owners are identified by their index.  When the simulation is driven by
+// stakeholders the index is the position of the stakeholder in the population,
+// otherwise all tickets belong to owner 0.
+type ownershipLedger struct {
+	owners       []ownerStats
+	maturing     map[int32]map[uint32]dcrutil.Amount
+	shareHistory []poolShareSample
+}
+
+// newOwnershipLedger returns a new ownership ledger which is ready for use.
+func newOwnershipLedger() *ownershipLedger {
+	return &ownershipLedger{
+		maturing: make(map[int32]map[uint32]dcrutil.Amount),
+	}
+}
+
+// stats returns the accounting for the passed owner, creating it if needed.
+func (l *ownershipLedger) stats(owner uint32) *ownerStats {
+	for uint32(len(l.owners)) <= owner {
+		l.owners = append(l.owners, ownerStats{})
+	}
+	return &l.owners[owner]
+}
+
+// addMaturing schedules the passed amount to become spendable by the owner at
+// the provided height.
+func (l *ownershipLedger) addMaturing(owner uint32, height int32, amount dcrutil.Amount) {
+	credits, ok := l.maturing[height]
+	if !ok {
+		credits = make(map[uint32]dcrutil.Amount)
+		l.maturing[height] = credits
+	}
+	credits[owner] += amount
+}
+
+// recordPurchase records the purchase of the passed ticket by its owner.
+func (l *ownershipLedger) recordPurchase(ticket *stakeTicket) {
+	stats := l.stats(ticket.owner)
+	stats.purchased++
+	stats.invested += ticket.price
+	stats.locked += ticket.price
+}
+
+// recordVote records that the passed ticket voted and earned the provided
+// reward, both of which become spendable by its owner at the given height.
+func (l *ownershipLedger) recordVote(ticket *stakeTicket, reward dcrutil.Amount, maturedHeight int32) {
+	stats := l.stats(ticket.owner)
+	stats.voteRewards += reward
+	stats.returned += ticket.price
+	stats.locked -= ticket.price
+	l.addMaturing(ticket.owner, maturedHeight, ticket.price+reward)
+}
+
+// recordRevocation records that the passed ticket was revoked and its price
+// becomes spendable by its owner at the given height.
+func (l *ownershipLedger) recordRevocation(ticket *stakeTicket, maturedHeight int32) {
+	stats := l.stats(ticket.owner)
+	stats.refunds += ticket.price
+	stats.locked -= ticket.price
+	l.addMaturing(ticket.owner, maturedHeight, ticket.price)
+}
+
+// recordAbandoned records that the passed ticket was missed or expired and will
+// never be revoked, so its price is never refunded to its owner.
+func (l *ownershipLedger) recordAbandoned(ticket *stakeTicket) {
+	stats := l.stats(ticket.owner)
+	stats.abandoned += ticket.price
+	stats.locked -= ticket.price
+}
+
+// ticketLive records that a ticket of the passed owner entered the live ticket
+// pool.
+func (l *ownershipLedger) ticketLive(owner uint32) {
+	l.stats(owner).liveTickets++
+}
+
+// ticketLeft records that a ticket of the passed owner left the live ticket
+// pool either by being selected to vote or by expiring.
+func (l *ownershipLedger) ticketLeft(owner uint32) {
+	l.stats(owner).liveTickets--
+}
+
+// maturedCredits removes and returns the amounts that become spendable by each
+// owner at the passed height.
+func (l *ownershipLedger) maturedCredits(height int32) map[uint32]dcrutil.Amount {
+	credits := l.maturing[height]
+	delete(l.maturing, height)
+	return credits
+}
+
+// samplePoolShare records the fraction of the live ticket pool held by each
+// owner as of the passed height.
+func (l *ownershipLedger) samplePoolShare(height int32, poolSize int) {
+	shares := make([]float64, len(l.owners))
+	if poolSize > 0 {
+		for i := range l.owners {
+			shares[i] = float64(l.owners[i].liveTickets) /
+				float64(poolSize)
+		}
+	}
+	l.shareHistory = append(l.shareHistory, poolShareSample{
+		height: height,
+		shares: shares,
+	})
+}
+
+// ownerName returns the name used to identify the passed owner in the results.
+func (s *simulator) ownerName(owner uint32) string {
+	if s.agents != nil {
+		if int(owner) < len(s.agents.agents) {
+			return s.agents.agents[owner].name
+		}
+		if owner == s.agents.bootstrapOwner() {
+			return "bootstrap"
+		}
+		if owner == s.agents.whaleOwner() {
+			return "scripted whale"
+		}
+	}
+	if owner == 0 {
+		return "all"
+	}
+	return fmt.Sprintf("owner %d", owner)
+}
+
+// ownerSummary houses the final accounting of a single owner of tickets as
+// reported in the simulation results.  All amounts are in atoms.
+type ownerSummary struct {
+	Name        string         `json:"name"`
+	Purchased   uint32         `json:"purchased"`
+	Invested    dcrutil.Amount `json:"invested"`
+	VoteRewards dcrutil.Amount `json:"voterewards"`
+	Refunds     dcrutil.Amount `json:"refunds"`
+	Locked      dcrutil.Amount `json:"locked"`
+	Abandoned   dcrutil.Amount `json:"abandoned"`
+	ROI         float64        `json:"roi"`
+	PoolShare   float64        `json:"poolshare"`
+}
+
+// ownerSummaries returns the final accounting of every owner of tickets.
+func (s *simulator) ownerSummaries() []ownerSummary {
+	l := s.ownership
+	poolSize := s.liveTickets.Len()
+	summaries := make([]ownerSummary, 0, len(l.owners))
+	for i := range l.owners {
+		stats := &l.owners[i]
+		var share float64
+		if poolSize > 0 {
+			share = float64(stats.liveTickets) / float64(poolSize)
+		}
+		summaries = append(summaries, ownerSummary{
+			Name:        s.ownerName(uint32(i)),
+			Purchased:   stats.purchased,
+			Invested:    stats.invested,
+			VoteRewards: stats.voteRewards,
+			Refunds:     stats.refunds,
+			Locked:      stats.locked,
+			Abandoned:   stats.abandoned,
+			ROI:         stats.roi(),
+			PoolShare:   share,
+		})
+	}
+	return summaries
+}
+
+// ownerResults returns the per-owner details shown in the simulation results.
+func ownerResults(summaries []ownerSummary) []map[string]string {
+	results := make([]map[string]string, 0, len(summaries))
+	for _, summary := range summaries {
+		results = append(results, map[string]string{
+			"Name":        summary.Name,
+			"Purchased":   strconv.FormatUint(uint64(summary.Purchased), 10),
+			"Invested":    summary.Invested.String(),
+			"VoteRewards": summary.VoteRewards.String(),
+			"Refunds":     summary.Refunds.String(),
+			"Locked":      summary.Locked.String(),
+			"Abandoned":   summary.Abandoned.String(),
+			"ROI":         strconv.FormatFloat(summary.ROI*100, 'f', 2, 64) + "%",
+			"PoolShare":   strconv.FormatFloat(summary.PoolShare*100, 'f', 2, 64) + "%",
+		})
+	}
+	return results
+}
+
+// poolShareCSV returns the pool share history of all owners as CSV data along
+// with the labels of each column.
+func (s *simulator) poolShareCSV() (string, []string) {
+	l := s.ownership
+	labels := make([]string, 0, len(l.owners)+1)
+	labels = append(labels, "Block")
+	for i := range l.owners {
+		labels = append(labels, s.ownerName(uint32(i)))
+	}
+
+	var csv bytes.Buffer
+	for _, sample := range l.shareHistory {
+		csv.WriteString(strconv.Itoa(int(sample.height)))
+		for i := range l.owners {
+			var share float64
+			if i < len(sample.shares) {
+				share = sample.shares[i]
+			}
+			csv.WriteRune(',')
+			csv.WriteString(strconv.FormatFloat(share*100, 'f', 4, 64))
+		}
+		csv.WriteRune('\n')
+	}
+	return csv.String(), labels
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/decred/dcrd/chaincfg"
+)
+
+// networkParams houses the chain parameters for all of the networks that can
+// be selected via the -net flag keyed by their name.
+var networkParams = map[string]*chaincfg.Params{
+	chaincfg.MainNetParams.Name: &chaincfg.MainNetParams,
+	chaincfg.TestNetParams.Name: &chaincfg.TestNetParams,
+	chaincfg.SimNetParams.Name:  &chaincfg.SimNetParams,
+}
+
+// findNetworkParams returns the chain parameters for the network with the
+// provided name.
+func findNetworkParams(name string) (*chaincfg.Params, error) {
+	params, ok := networkParams[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(networkParams))
+		for name := range networkParams {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown network %q -- available networks "+
+			"are %s", name, strings.Join(names, ", "))
+	}
+	return params, nil
+}
+
+// paramsOverrides defines the chain parameters which may be overridden by a
+// custom parameters file.  Fields which are not specified in the file are left
+// as nil and therefore do not modify the base network parameters.
+//
+// The JSON keys are the same as the names of the fields in chaincfg.Params.
+type paramsOverrides struct {
+	Name                     *string
+	MinimumStakeDiff         *int64
+	TicketPoolSize           *uint16
+	TicketsPerBlock          *uint16
+	TicketMaturity           *uint16
+	TicketExpiry             *uint32
+	CoinbaseMaturity         *uint16
+	TicketPoolSizeWeight     *uint16
+	StakeDiffAlpha           *int64
+	StakeDiffWindowSize      *int64
+	StakeDiffWindows         *int64
+	MaxFreshStakePerBlock    *uint8
+	StakeEnabledHeight       *int64
+	StakeValidationHeight    *int64
+	RetargetAdjustmentFactor *int64
+	BaseSubsidy              *int64
+	MulSubsidy               *int64
+	DivSubsidy               *int64
+	SubsidyReductionInterval *int64
+	WorkRewardProportion     *uint16
+	StakeRewardProportion    *uint16
+	BlockTaxProportion       *uint16
+}
+
+// apply returns a copy of the passed chain parameters with all of the
+// specified overrides applied.  The passed parameters are not modified.
+func (o *paramsOverrides) apply(base *chaincfg.Params) *chaincfg.Params {
+	params := *base
+	overrides := reflect.ValueOf(o).Elem()
+	target := reflect.ValueOf(&params).Elem()
+	for i := 0; i < overrides.NumField(); i++ {
+		field := overrides.Field(i)
+		if field.IsNil() {
+			continue
+		}
+		name := overrides.Type().Field(i).Name
+		target.FieldByName(name).Set(field.Elem())
+	}
+	return &params
+}
+
+// validateParams ensures the passed chain parameters are sane enough for the
+// simulator to use them without dividing by zero or otherwise producing
+// nonsensical results.
+func validateParams(params *chaincfg.Params) error {
+	switch {
+	case params.TicketsPerBlock == 0:
+		return fmt.Errorf("TicketsPerBlock must be greater than zero")
+	case params.TicketPoolSize == 0:
+		return fmt.Errorf("TicketPoolSize must be greater than zero")
+	case params.TicketExpiry == 0:
+		return fmt.Errorf("TicketExpiry must be greater than zero")
+	case params.MaxFreshStakePerBlock == 0:
+		return fmt.Errorf("MaxFreshStakePerBlock must be greater than zero")
+	case params.StakeDiffWindowSize <= 0:
+		return fmt.Errorf("StakeDiffWindowSize must be greater than zero")
+	case params.StakeDiffWindows <= 0:
+		return fmt.Errorf("StakeDiffWindows must be greater than zero")
+	case params.StakeDiffAlpha < 0:
+		return fmt.Errorf("StakeDiffAlpha must not be negative")
+	case params.RetargetAdjustmentFactor <= 1:
+		return fmt.Errorf("RetargetAdjustmentFactor must be greater than one")
+	case params.MinimumStakeDiff <= 0:
+		return fmt.Errorf("MinimumStakeDiff must be greater than zero")
+	case params.SubsidyReductionInterval <= 0:
+		return fmt.Errorf("SubsidyReductionInterval must be greater than zero")
+	case params.DivSubsidy <= 0:
+		return fmt.Errorf("DivSubsidy must be greater than zero")
+	case params.TotalSubsidyProportions() == 0:
+		return fmt.Errorf("the subsidy proportions must not all be zero")
+	case params.StakeValidationHeight <= int64(params.CoinbaseMaturity):
+		return fmt.Errorf("StakeValidationHeight must be greater than " +
+			"CoinbaseMaturity")
+	}
+
+	// The ticket price algorithms shift by the product of the number of
+	// windows and the alpha, so ensure it is well within range.
+	if params.StakeDiffWindows*params.StakeDiffAlpha > 32 {
+		return fmt.Errorf("the product of StakeDiffWindows and " +
+			"StakeDiffAlpha must not exceed 32")
+	}
+
+	return nil
+}
+
+// loadParamsFile returns a copy of the passed base chain parameters with the
+// overrides specified in the JSON file at the provided path applied.  The
+// resulting parameters are validated.
+func loadParamsFile(base *chaincfg.Params, path string) (*chaincfg.Params, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reject any keys which do not refer to an overridable parameter since
+	// they are almost certainly a mistake that would otherwise silently be
+	// ignored.
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(contents, &keys); err != nil {
+		return nil, fmt.Errorf("unable to parse params file %q: %v", path,
+			err)
+	}
+	overridesType := reflect.TypeOf(paramsOverrides{})
+	for key := range keys {
+		if _, ok := overridesType.FieldByName(key); !ok {
+			return nil, fmt.Errorf("params file %q contains unsupported "+
+				"parameter %q", path, key)
+		}
+	}
+
+	var overrides paramsOverrides
+	if err := json.Unmarshal(contents, &overrides); err != nil {
+		return nil, fmt.Errorf("unable to parse params file %q: %v", path,
+			err)
+	}
+	params := overrides.apply(base)
+	if err := validateParams(params); err != nil {
+		return nil, fmt.Errorf("invalid params file %q: %v", path, err)
+	}
+	return params, nil
+}
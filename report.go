@@ -0,0 +1,54 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// serveResults serves the HTML results file at the provided path over HTTP on
+// the given address until the process is terminated.  The results are served
+// at the root of the server.
+func serveResults(addr, resultsPath string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("unable to serve results: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, resultsPath)
+	})
+	fmt.Printf("Serving results at http://%s/ (press ctrl+c to exit)\n",
+		listener.Addr())
+	return http.Serve(listener, mux)
+}
+
+// showResults presents the HTML results file at the provided path by either
+// serving it over HTTP on the passed address when it is not empty, reporting
+// where it was written when the browser is disabled, or opening it in a
+// browser.
+func showResults(resultsPath, serveAddr string, noBrowser bool) error {
+	switch {
+	case serveAddr != "":
+		return serveResults(serveAddr, resultsPath)
+
+	case noBrowser:
+		fmt.Printf("Wrote results to %q.\n", resultsPath)
+
+	default:
+		if !openBrowser(resultsPath) {
+			return fmt.Errorf("unable to open results file %q in "+
+				"browser", resultsPath)
+		}
+	}
+	return nil
+}
@@ -1,12 +1,6 @@
 package main
 
-var resultsTmplText = `
-<!doctype html>
-<html lang="en">
-  <head>
-    <meta charset="utf-8">
-    <meta http-equiv="X-UA-Compatible" content="IE=edge">
-    <title>Simulation Results</title>
+var resultsTmplText = `{{define "libs"}}
     <script type="text/javascript">
       /* dygraph-2.0.0.min.js */
       /*! @license Copyright 2017 Dan Vanderkam (danvdk@gmail.com) MIT-licensed (http://opensource.org/licenses/MIT) */
@@ -24,9 +18,8 @@ var resultsTmplText = `
       /* dygraph-2.0.0.min.css */
       .dygraph-legend{position:absolute;font-size:14px;z-index:10;width:250px;background:#fff;line-height:normal;text-align:left;overflow:hidden}.dygraph-legend-line{display:inline-block;position:relative;bottom:.5ex;padding-left:1em;height:1px;border-bottom-width:2px;border-bottom-style:solid}.dygraph-legend-dash{display:inline-block;position:relative;bottom:.5ex;height:1px;border-bottom-width:2px;border-bottom-style:solid}.dygraph-roller{position:absolute;z-index:10}.dygraph-annotation{position:absolute;z-index:10;overflow:hidden}.dygraph-default-annotation{border:1px solid #000;background-color:#fff;text-align:center}.dygraph-axis-label{z-index:10;line-height:normal;overflow:hidden;color:#000}.dygraph-title{font-weight:700;z-index:10;text-align:center;color:#0c1e3e}.dygraph-xlabel{text-align:center}.dygraph-label-rotate-left{text-align:center;transform:rotate(90deg);-webkit-transform:rotate(90deg);-moz-transform:rotate(90deg);-o-transform:rotate(90deg);-ms-transform:rotate(90deg)}.dygraph-label-rotate-right{text-align:center;transform:rotate(-90deg);-webkit-transform:rotate(-90deg);-moz-transform:rotate(-90deg);-o-transform:rotate(-90deg);-ms-transform:rotate(-90deg);}
     </style>
-  </head>
-  <body style="margin: 0px; padding: 0px;">
-    <div id="container" style="width: 100%;">
+    {{- end}}
+{{define "navbar"}}
       <div id="navbar" style="width: 100%; height: 70px; background-color: #0c1e3e;">
         <svg version="1.1" viewBox="0 0 299 56" style="width: 186px; height: 34px; padding-left: 100px; padding-top: 18px;">
           <g id="svg-decred-logo">
@@ -52,8 +45,28 @@ var resultsTmplText = `
           </g>
         </svg>
       </div>
+      {{- end}}
+<!doctype html>
+<html lang="en">
+  <head>
+    <meta charset="utf-8">
+    <meta http-equiv="X-UA-Compatible" content="IE=edge">
+    <title>Simulation Results</title>
+    {{template "libs"}}
+  </head>
+  <body style="margin: 0px; padding: 0px;">
+    <div id="container" style="width: 100%;">
+      {{template "navbar"}}
       <div style="width: 95%;">
         <table>
+          <tr>
+            <td>Stake Difficulty Algorithm</td>
+            <td>{{.Algorithm}}</td>
+          </tr>
+          <tr>
+            <td>Random Seed</td>
+            <td>{{.Seed}}</td>
+          </tr>
           <tr>
             <td>Min Ticket Price</td>
             <td>{{.MinTicketPrice}}</td>
@@ -74,6 +87,18 @@ var resultsTmplText = `
             <td>Total Expired Tickets</td>
             <td>{{.NumExpired}}</td>
           </tr>
+          <tr>
+            <td>Total Missed Tickets</td>
+            <td>{{.NumMissed}}</td>
+          </tr>
+          <tr>
+            <td>Unrevoked Tickets</td>
+            <td>{{.NumUnrevoked}}</td>
+          </tr>
+          <tr>
+            <td>Coins Locked in Unrevoked Tickets</td>
+            <td>{{.UnrevokedAmount}}</td>
+          </tr>
           <tr>
             <td>Min Pool Size</td>
             <td>{{.MinPoolSize}}</td>
@@ -82,6 +107,14 @@ var resultsTmplText = `
             <td>Max Pool Size</td>
             <td>{{.MaxPoolSize}}</td>
           </tr>
+          <tr>
+            <td>Invalidated Blocks</td>
+            <td>{{.NumInvalidated}}</td>
+          </tr>
+          <tr>
+            <td>Subsidy Removed by Invalidation</td>
+            <td>{{.InvalidatedSubsidy}}</td>
+          </tr>
           <tr>
             <td>Total Coin Supply</td>
             <td>{{.CoinSupply}}</td>
@@ -90,6 +123,54 @@ var resultsTmplText = `
             <td>Spendable Coin Supply</td>
             <td>{{.SpendableSupply}}</td>
           </tr>
+          {{range .Stability}}
+          <tr>
+            <td>{{.Name}}</td>
+            <td>{{.Value}}</td>
+          </tr>
+          {{end}}
+        </table>
+        {{if .Stakeholders}}
+        <table style="margin-top: 1em;">
+          <tr>
+            <th>Stakeholder</th>
+            <th>Strategy</th>
+            <th>Final Balance</th>
+          </tr>
+          {{range .Stakeholders}}
+          <tr>
+            <td>{{.Name}}</td>
+            <td>{{.Strategy}}</td>
+            <td>{{.Balance}}</td>
+          </tr>
+          {{end}}
+        </table>
+        {{end}}
+        <table style="margin-top: 1em;">
+          <tr>
+            <th>Owner</th>
+            <th>Tickets Purchased</th>
+            <th>Invested</th>
+            <th>Vote Rewards</th>
+            <th>Revocation Refunds</th>
+            <th>Locked Funds</th>
+            <th>Never Revoked</th>
+            <th>ROI</th>
+            <th>Pool Share</th>
+          </tr>
+          {{range .Owners}}
+          <tr>
+            <td>{{.Name}}</td>
+            <td>{{.Purchased}}</td>
+            <td>{{.Invested}}</td>
+            <td>{{.VoteRewards}}</td>
+            <td>{{.Refunds}}</td>
+            <td>{{.Locked}}</td>
+            <td>{{.Abandoned}}</td>
+            <td>{{.ROI}}</td>
+            <td>{{.PoolShare}}</td>
+          </tr>
+          {{end}}
         </table>
       </div>
       <div id="charts" style="width: 95%; text-align: center;">
@@ -98,10 +179,20 @@ var resultsTmplText = `
         <div style="width: 50%; float: left; margin-top: 2em;">
           <canvas id="histogram"></canvas>
         </div>
+        <div id="poolsharediv" style="width: 50%; float: right; margin-top: 2em;"></div>
       </div>
     </div>
 
     <script>
+      // Mark the heights at which the stake difficulty algorithm changes.
+      var activations = {{.Activations}};
+      var markActivations = function(canvas, area, g) {
+        canvas.fillStyle = '#ed6d47';
+        activations.forEach(function(height) {
+          canvas.fillRect(g.toDomXCoord(height), area.y, 2, area.h);
+        });
+      };
+
       window.onload = function() {
         var csv = "{{.PoolSizeCSV}}";
         var poolSizeGraph = new Dygraph(document.getElementById("poolsizediv"), csv,
@@ -113,6 +204,7 @@ var resultsTmplText = `
             legend: 'always',
             colors: ['#0c1e3e'],
             fillGraph: true,
+            underlayCallback: markActivations,
             animatedZooms: true,
             plugins : [
                 Dygraph.Plugins.Unzoom
@@ -131,6 +223,23 @@ var resultsTmplText = `
             colors: ['#2972ff'],
             fillGraph: true,
             drawPoints: true, 
+            underlayCallback: markActivations,
+            animatedZooms: true,
+            plugins : [
+                Dygraph.Plugins.Unzoom
+            ]
+          }
+        );
+
+        var csv = "{{.PoolShareCSV}}";
+        var poolShareGraph = new Dygraph(document.getElementById("poolsharediv"), csv,
+          {
+            title: 'Live Ticket Pool Share Per Owner',
+            labels: {{.PoolShareLabels}},
+            xlabel: 'Block Height',
+            ylabel: 'Pool Share (%)',
+            legend: 'always',
+            stackedGraph: true,
             animatedZooms: true,
             plugins : [
                 Dygraph.Plugins.Unzoom
@@ -0,0 +1,131 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// neverRevokeHeight is the revocation height assigned to tickets that will
+// never be revoked.
+const neverRevokeHeight = math.MaxInt32
+
+// revocationModelConfig houses the configuration used to create a revocation
+// model.
+type revocationModelConfig struct {
+	// Model is the name of the distribution used for the delay between a
+	// ticket becoming eligible for revocation and actually being revoked.
+	Model string `json:"model"`
+
+	// Delay is the number of blocks the revocation is delayed for the
+	// fixed model, the maximum delay for the uniform model, and the mean
+	// delay for the exponential model.
+	Delay uint32 `json:"delay"`
+
+	// NeverFraction is the fraction of missed and expired tickets that are
+	// never revoked, for example, because the wallet was lost.
+	NeverFraction float64 `json:"neverfraction"`
+}
+
+// revocationModel models the behavior of wallets when revoking missed and
+// expired tickets.  It determines how many blocks after a ticket becomes
+// eligible for revocation it is actually revoked, if ever.
+type revocationModel struct {
+	model         string
+	delay         uint32
+	neverFraction float64
+}
+
+// newRevocationModel returns a revocation model created according to the
+// passed configuration.
+func newRevocationModel(cfg *revocationModelConfig) (*revocationModel, error) {
+	model := cfg.Model
+	switch model {
+	case "":
+		model = "immediate"
+	case "immediate", "fixed", "uniform", "exponential":
+	default:
+		return nil, fmt.Errorf("unknown revocation model %q -- available "+
+			"models are immediate, fixed, uniform, and exponential",
+			cfg.Model)
+	}
+	if err := validateRate("neverrevoke", cfg.NeverFraction); err != nil {
+		return nil, err
+	}
+
+	return &revocationModel{
+		model:         model,
+		delay:         cfg.Delay,
+		neverFraction: cfg.NeverFraction,
+	}, nil
+}
+
+// revokeHeight returns the height of the block a ticket that first becomes
+// eligible for revocation at the passed height is revoked in.
+// neverRevokeHeight is returned for tickets that are never revoked.
+func (m *revocationModel) revokeHeight(eligibleHeight int32, rng *rand.Rand) int32 {
+	if m.neverFraction > 0 && rng.Float64() < m.neverFraction {
+		return neverRevokeHeight
+	}
+
+	var delay int64
+	switch m.model {
+	case "fixed":
+		delay = int64(m.delay)
+	case "uniform":
+		delay = rng.Int63n(int64(m.delay) + 1)
+	case "exponential":
+		delay = int64(rng.ExpFloat64() * float64(m.delay))
+	}
+
+	height := int64(eligibleHeight) + delay
+	if height >= neverRevokeHeight {
+		return neverRevokeHeight - 1
+	}
+	return int32(height)
+}
+
+// addUnrevokedTickets adds the passed tickets, which are either missed or
+// expired, to the pool of unrevoked tickets and assigns them the height they
+// will be revoked at according to the revocation model.  The tickets become
+// eligible for revocation in the block after the passed height.
+//
+// The unrevoked tickets are kept sorted by the height they are revoked at,
+// while otherwise maintaining the order they were added in, so that the
+// tickets which are due to be revoked are always at the front.
+func (s *simulator) addUnrevokedTickets(tickets []*stakeTicket, height int32) {
+	for _, ticket := range tickets {
+		ticket.revokeHeight = height + 1
+		if s.revocationModel != nil {
+			ticket.revokeHeight = s.revocationModel.revokeHeight(
+				height+1, s.rng)
+		}
+		if ticket.revokeHeight == neverRevokeHeight {
+			s.ownership.recordAbandoned(ticket)
+		}
+
+		// Insert the ticket after all tickets that are revoked at the
+		// same height or earlier.
+		unrevoked := s.unrevokedTickets
+		i := sort.Search(len(unrevoked), func(i int) bool {
+			return unrevoked[i].revokeHeight > ticket.revokeHeight
+		})
+		unrevoked = append(unrevoked, nil)
+		copy(unrevoked[i+1:], unrevoked[i:])
+		unrevoked[i] = ticket
+		s.unrevokedTickets = unrevoked
+	}
+}
+
+// dueRevocations returns the number of unrevoked tickets that are due to be
+// revoked in the block at the passed height.
+func (s *simulator) dueRevocations(height int32) int {
+	return sort.Search(len(s.unrevokedTickets), func(i int) bool {
+		return s.unrevokedTickets[i].revokeHeight > height
+	})
+}
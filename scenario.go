@@ -0,0 +1,158 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// scenarioDemand houses the demand model section of a scenario file.
+type scenarioDemand struct {
+	Model  *string            `json:"model"`
+	Params map[string]float64 `json:"params"`
+}
+
+// scenarioVotes houses the missed vote model section of a scenario file.  See
+// voteModelConfig for details about each field.
+type scenarioVotes struct {
+	Model          *string  `json:"model"`
+	MissRate       *float64 `json:"missrate"`
+	OutageProb     *float64 `json:"outageprob"`
+	OutageLength   *uint32  `json:"outagelength"`
+	OutageMissRate *float64 `json:"outagemissrate"`
+}
+
+// scenarioRevocations houses the revocation model section of a scenario file.
+// See revocationModelConfig for details about each field.
+type scenarioRevocations struct {
+	Model         *string  `json:"model"`
+	Delay         *uint32  `json:"delay"`
+	NeverFraction *float64 `json:"neverfraction"`
+}
+
+// scenario describes a full simulation so that it can be versioned and shared.
+// Fields which are not specified in the scenario file are left as nil and
+// therefore do not modify the defaults.
+type scenario struct {
+	Network     *string              `json:"network"`
+	Params      *paramsOverrides     `json:"params"`
+	Algorithm   *string              `json:"algorithm"`
+	Seed        *int64               `json:"seed"`
+	NumBlocks   *uint64              `json:"numblocks"`
+	RampUpRate  *float64             `json:"rampuprate"`
+	StakingCap  *float64             `json:"stakingcap"`
+	Demand      *scenarioDemand      `json:"demand"`
+	Agents      *string              `json:"agents"`
+	Events      *string              `json:"events"`
+	Votes       *scenarioVotes       `json:"votes"`
+	Revocations *scenarioRevocations `json:"revocations"`
+	InvalidProb *float64             `json:"invalidprob"`
+}
+
+// loadScenario loads the scenario from the JSON file at the provided path.
+// Keys which are not part of the scenario are rejected since they are almost
+// certainly a mistake that would otherwise silently be ignored.
+//
+// The paths to the stakeholders and events files are interpreted relative to
+// the directory containing the scenario file so that scenarios and the files
+// they reference can be kept together.
+func loadScenario(path string) (*scenario, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sc scenario
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&sc); err != nil {
+		return nil, fmt.Errorf("unable to parse scenario file %q: %v",
+			path, err)
+	}
+
+	if sc.Agents != nil && *sc.Agents != "default" &&
+		!filepath.IsAbs(*sc.Agents) {
+
+		agentsPath := filepath.Join(filepath.Dir(path), *sc.Agents)
+		sc.Agents = &agentsPath
+	}
+	if sc.Events != nil && !filepath.IsAbs(*sc.Events) {
+		eventsPath := filepath.Join(filepath.Dir(path), *sc.Events)
+		sc.Events = &eventsPath
+	}
+	return &sc, nil
+}
+
+// flagValues returns the values specified by the scenario keyed by the name of
+// the command line flag they are associated with.  The chain parameter
+// overrides are not included since there is no flag for them.
+func (sc *scenario) flagValues() map[string]string {
+	values := make(map[string]string)
+	setString := func(name string, value *string) {
+		if value != nil {
+			values[name] = *value
+		}
+	}
+	setFloat := func(name string, value *float64) {
+		if value != nil {
+			values[name] = strconv.FormatFloat(*value, 'g', -1, 64)
+		}
+	}
+	setUint := func(name string, value *uint32) {
+		if value != nil {
+			values[name] = strconv.FormatUint(uint64(*value), 10)
+		}
+	}
+
+	setString("net", sc.Network)
+	setString("algo", sc.Algorithm)
+	if sc.Seed != nil {
+		values["seed"] = strconv.FormatInt(*sc.Seed, 10)
+	}
+	if sc.NumBlocks != nil {
+		values["numblocks"] = strconv.FormatUint(*sc.NumBlocks, 10)
+	}
+	setFloat("rampup", sc.RampUpRate)
+	setFloat("stakingcap", sc.StakingCap)
+	if sc.Demand != nil {
+		setString("demand", sc.Demand.Model)
+		if sc.Demand.Params != nil {
+			names := make([]string, 0, len(sc.Demand.Params))
+			for name := range sc.Demand.Params {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			pairs := make([]string, 0, len(names))
+			for _, name := range names {
+				value := sc.Demand.Params[name]
+				pairs = append(pairs, name+"="+
+					strconv.FormatFloat(value, 'g', -1, 64))
+			}
+			values["demandparams"] = strings.Join(pairs, ",")
+		}
+	}
+	setString("agents", sc.Agents)
+	setString("events", sc.Events)
+	if sc.Votes != nil {
+		setString("votemodel", sc.Votes.Model)
+		setFloat("missrate", sc.Votes.MissRate)
+		setFloat("outageprob", sc.Votes.OutageProb)
+		setUint("outagelen", sc.Votes.OutageLength)
+		setFloat("outagemissrate", sc.Votes.OutageMissRate)
+	}
+	if sc.Revocations != nil {
+		setString("revokemodel", sc.Revocations.Model)
+		setUint("revokedelay", sc.Revocations.Delay)
+		setFloat("neverrevoke", sc.Revocations.NeverFraction)
+	}
+	setFloat("invalidprob", sc.InvalidProb)
+	return values
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/decred/dcrd/chaincfg"
+)
+
+// simConfig houses everything needed to create and run a simulation.  It
+// allows the same scenario to be run multiple times, for example to compare
+// stake difficulty algorithms, since every simulator it creates starts from
+// exactly the same state.
+type simConfig struct {
+	params           *chaincfg.Params
+	algo             *stakeDiffAlgorithm
+	replayAlgo       *stakeDiffAlgorithm
+	seed             int64
+	csvPath          string
+	replayHeight     int32
+	continueBlocks   uint64
+	lenient          bool
+	numBlocks        uint64
+	voteModel        voteModelConfig
+	revocationModel  revocationModelConfig
+	invalidationProb float64
+	rampUpRate       float64
+	stakingCap       float64
+	demandInfo       *demandModelInfo
+	demandParams     map[string]float64
+	agents           []stakeholderConfig
+	events           []eventConfig
+	checkpoint       *simCheckpoint
+}
+
+// newSimulator returns a new simulator configured according to the simulation
+// configuration.  All of the models are created fresh so that no state is
+// shared with any other simulators created from the same configuration.
+func (cfg *simConfig) newSimulator() (*simulator, error) {
+	if err := validateRate("invalidprob", cfg.invalidationProb); err != nil {
+		return nil, err
+	}
+	if err := validateRate("rampup", cfg.rampUpRate); err != nil {
+		return nil, err
+	}
+	if err := validateRate("stakingcap", cfg.stakingCap); err != nil {
+		return nil, err
+	}
+	voteModel, err := newMissedVoteModel(&cfg.voteModel)
+	if err != nil {
+		return nil, err
+	}
+	revocationModel, err := newRevocationModel(&cfg.revocationModel)
+	if err != nil {
+		return nil, err
+	}
+	demandModel, err := cfg.demandInfo.newDemandModel(cfg.demandParams)
+	if err != nil {
+		return nil, err
+	}
+	var agents *agentPopulation
+	if cfg.agents != nil {
+		agents, err = newAgentPopulation(cfg.agents)
+		if err != nil {
+			return nil, err
+		}
+	}
+	events, err := newEventSchedule(cfg.events)
+	if err != nil {
+		return nil, err
+	}
+
+	sim := newSimulator(cfg.params)
+	sim.setSeed(cfg.seed)
+	sim.demandModel = demandModel
+	sim.agents = agents
+	sim.voteModel = voteModel
+	sim.revocationModel = revocationModel
+	sim.invalidationProb = cfg.invalidationProb
+	sim.rampUpRate = cfg.rampUpRate
+	sim.stakingCap = cfg.stakingCap
+	sim.events = events
+	if cfg.lenient && cfg.csvPath != "" {
+		sim.validator = newReplayValidator()
+	}
+	if cfg.checkpoint != nil {
+		if err := cfg.checkpoint.restore(sim); err != nil {
+			return nil, err
+		}
+	}
+
+	// Configure the algorithm after restoring any checkpoint so the
+	// algorithms that priced the restored blocks are retained.
+	if cfg.isHybrid() {
+		sim.setStakeDiffAlgorithm(cfg.replayAlgo)
+	} else {
+		sim.setStakeDiffAlgorithm(cfg.algo)
+	}
+	return sim, nil
+}
+
+// isHybrid returns whether or not the configuration describes a hybrid
+// simulation which replays the CSV input data and then continues with a full
+// simulation.
+func (cfg *simConfig) isHybrid() bool {
+	return cfg.csvPath != "" && cfg.continueBlocks > 0
+}
+
+// describe returns a human-readable description of the simulation that is
+// run according to the configuration.
+func (cfg *simConfig) describe() string {
+	var events string
+	if len(cfg.events) > 0 {
+		events = fmt.Sprintf(" with %d scripted events", len(cfg.events))
+	}
+	if cp := cfg.checkpoint; cp != nil && len(cp.Blocks) > 0 {
+		tipHeight := cp.RootHeight + int32(len(cp.Blocks)) - 1
+		events += fmt.Sprintf(" resumed from height %d", tipHeight)
+	}
+	demand := fmt.Sprintf("demand model %q (%s)", cfg.demandInfo.name,
+		formatDemandParams(cfg.demandParams))
+	if cfg.agents != nil {
+		demand = fmt.Sprintf("%d stakeholders", len(cfg.agents))
+	}
+
+	if cfg.csvPath != "" {
+		var replay string
+		if cfg.replayHeight > 0 {
+			replay = fmt.Sprintf(" up to height %d", cfg.replayHeight)
+		}
+		if !cfg.isHybrid() {
+			return fmt.Sprintf("from %q%s", cfg.csvPath, replay)
+		}
+		return fmt.Sprintf("from %q%s using algorithm %q and then for "+
+			"%d more blocks using %s%s", cfg.csvPath, replay,
+			cfg.replayAlgo.name, cfg.continueBlocks, demand, events)
+	}
+	return fmt.Sprintf("for %d blocks using %s%s", cfg.numBlocks, demand,
+		events)
+}
+
+// run runs the passed simulator, which must have been created from the
+// configuration, either from the CSV input data or for the configured number
+// of blocks.  The verifier is only used when running from CSV data and may be
+// nil.
+//
+// Hybrid simulations replay the CSV input data with the replay algorithm and
+// then switch to the configured algorithm to continue with a full simulation.
+func (cfg *simConfig) run(sim *simulator, verifier *replayVerifier) error {
+	if cfg.csvPath != "" {
+		err := sim.simulateFromCSV(cfg.csvPath, verifier,
+			cfg.replayHeight)
+		if err != nil || !cfg.isHybrid() {
+			return err
+		}
+		sim.setStakeDiffAlgorithm(cfg.algo)
+		return sim.simulate(cfg.continueBlocks)
+	}
+	return sim.simulate(cfg.numBlocks)
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// stakeDiffAlgorithm describes a named ticket price (aka stake difficulty)
+// algorithm that the simulator is able to use to calculate the required ticket
+// price for the block after the current tip.
+type stakeDiffAlgorithm struct {
+	// name is the unique name used to select the algorithm.
+	name string
+
+	// description is a short human-readable description of the algorithm.
+	description string
+
+	// calcFunc calculates the required ticket price for the block after
+	// the current tip of the provided simulator.
+	calcFunc func(s *simulator) int64
+
+	// schedule houses the registered algorithms that make up the algorithm
+	// along with the heights they activate at when it is a schedule.  It
+	// is nil for the registered algorithms.
+	schedule stakeDiffSchedule
+}
+
+// activations returns the schedule of registered algorithms the algorithm is
+// made up of.  Registered algorithms are active from the genesis block.
+func (algo *stakeDiffAlgorithm) activations() stakeDiffSchedule {
+	if algo.schedule != nil {
+		return algo.schedule
+	}
+	return stakeDiffSchedule{{algo: algo, height: 0}}
+}
+
+// stakeDiffActivation describes a registered ticket price algorithm along with
+// the height of the first block it calculates the ticket price for.
+type stakeDiffActivation struct {
+	algo   *stakeDiffAlgorithm
+	height int32
+}
+
+// stakeDiffSchedule houses a sequence of registered ticket price algorithms
+// ordered by the heights they activate at.  This allows modelling consensus
+// changes that switch algorithms at a specific height.
+type stakeDiffSchedule []stakeDiffActivation
+
+// activeAt returns the algorithm of the schedule that calculates the ticket
+// price for the block at the passed height.
+func (sched stakeDiffSchedule) activeAt(height int32) *stakeDiffAlgorithm {
+	algo := sched[0].algo
+	for _, activation := range sched[1:] {
+		if height < activation.height {
+			break
+		}
+		algo = activation.algo
+	}
+	return algo
+}
+
+// activationHeights returns the heights at which the schedule switches
+// algorithms.  The result is never nil so it is always a valid JSON array.
+func (sched stakeDiffSchedule) activationHeights() []int32 {
+	heights := make([]int32, 0, len(sched))
+	for _, activation := range sched[1:] {
+		heights = append(heights, activation.height)
+	}
+	return heights
+}
+
+// String returns the schedule in the same form it is specified with, such as
+// v1+dcp0001@5000.  Schedules with a single algorithm are just its name.
+func (sched stakeDiffSchedule) String() string {
+	parts := make([]string, 0, len(sched))
+	for i, activation := range sched {
+		if i == 0 {
+			parts = append(parts, activation.algo.name)
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s@%d",
+			activation.algo.name, activation.height))
+	}
+	return strings.Join(parts, "+")
+}
+
+// parseStakeDiffSchedule parses the passed algorithm schedule, such as
+// v1+dcp0001@5000 to use v1 until height 5000 and dcp0001 from then on, and
+// returns an algorithm that follows it.  Every algorithm after the first one
+// must specify an activation height which is greater than the previous one.
+func parseStakeDiffSchedule(spec string) (*stakeDiffAlgorithm, error) {
+	var sched stakeDiffSchedule
+	for i, part := range strings.Split(spec, "+") {
+		name, height := strings.TrimSpace(part), int64(0)
+		if idx := strings.LastIndex(name, "@"); idx >= 0 {
+			var err error
+			height, err = strconv.ParseInt(name[idx+1:], 10, 32)
+			if err != nil || height <= 0 {
+				return nil, fmt.Errorf("invalid activation height "+
+					"in algorithm schedule %q: %q", spec,
+					name[idx+1:])
+			}
+			name = name[:idx]
+		}
+		switch {
+		case i == 0 && height != 0:
+			return nil, fmt.Errorf("the first algorithm in schedule "+
+				"%q must not have an activation height", spec)
+		case i != 0 && height == 0:
+			return nil, fmt.Errorf("algorithm %q in schedule %q "+
+				"requires an activation height", name, spec)
+		case i != 0 && int32(height) <= sched[i-1].height:
+			return nil, fmt.Errorf("activation heights in schedule "+
+				"%q must be increasing", spec)
+		}
+		algo, err := findStakeDiffAlgorithm(name)
+		if err != nil {
+			return nil, err
+		}
+		if i != 0 && algo == sched[i-1].algo {
+			return nil, fmt.Errorf("algorithm %q is already active "+
+				"prior to height %d in schedule %q", algo.name,
+				height, spec)
+		}
+		sched = append(sched, stakeDiffActivation{
+			algo:   algo,
+			height: int32(height),
+		})
+	}
+
+	descs := make([]string, 0, len(sched))
+	for i, activation := range sched {
+		if i == 0 {
+			descs = append(descs, activation.algo.name)
+			continue
+		}
+		descs = append(descs, fmt.Sprintf("%s from height %d",
+			activation.algo.name, activation.height))
+	}
+	return &stakeDiffAlgorithm{
+		name:        sched.String(),
+		description: strings.Join(descs, ", then "),
+		calcFunc: func(s *simulator) int64 {
+			var nextHeight int32
+			if s.tip != nil {
+				nextHeight = s.tip.height + 1
+			}
+			return sched.activeAt(nextHeight).calcFunc(s)
+		},
+		schedule: sched,
+	}, nil
+}
+
+// stakeDiffAlgorithms houses all of the ticket price algorithms that are
+// available to the simulator.
+//
+// NOTE: Proposed algorithms should be added here in order to make them
+// selectable via the -algo flag.
+var stakeDiffAlgorithms = []stakeDiffAlgorithm{
+	{
+		name:        "v1",
+		description: "Mainnet algorithm as of March 2017",
+		calcFunc:    (*simulator).curCalcNextStakeDiff,
+	},
+	{
+		name:        "dcp0001",
+		description: "Stake difficulty algorithm v2 defined by DCP0001",
+		calcFunc:    (*simulator).calcNextStakeDiffDCP0001,
+	},
+	{
+		name: "mainnet",
+		description: "Same rules as mainnet -- v1 until DCP0001 " +
+			"activation, then dcp0001",
+		calcFunc: (*simulator).calcNextStakeDiffMainnet,
+	},
+}
+
+// defaultStakeDiffAlgorithm is the name of the ticket price algorithm to use
+// when none is specified.
+const defaultStakeDiffAlgorithm = "v1"
+
+// findStakeDiffAlgorithm returns the registered ticket price algorithm with
+// the provided name.  An error is returned when there is no such algorithm.
+//
+// Schedules of algorithms, such as v1+dcp0001@5000, are also accepted.
+func findStakeDiffAlgorithm(name string) (*stakeDiffAlgorithm, error) {
+	if strings.Contains(name, "+") {
+		return parseStakeDiffSchedule(name)
+	}
+	for i := range stakeDiffAlgorithms {
+		algo := &stakeDiffAlgorithms[i]
+		if strings.EqualFold(algo.name, name) {
+			return algo, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown stake difficulty algorithm %q -- use "+
+		"-listalgos to show the available algorithms", name)
+}
+
+// listStakeDiffAlgorithms writes the names and descriptions of all registered
+// ticket price algorithms to the passed writer.
+func listStakeDiffAlgorithms(w io.Writer) {
+	fmt.Fprintln(w, "Available stake difficulty algorithms:")
+	for _, algo := range stakeDiffAlgorithms {
+		defaultStr := ""
+		if algo.name == defaultStakeDiffAlgorithm {
+			defaultStr = " (default)"
+		}
+		fmt.Fprintf(w, "  %-10s %s%s\n", algo.name, algo.description,
+			defaultStr)
+	}
+	fmt.Fprintln(w, "\nAlgorithms may be switched at activation heights "+
+		"with a schedule such as v1+dcp0001@5000.")
+}
+
+// setStakeDiffAlgorithm configures the simulator to use the passed ticket price
+// algorithm to calculate the next required ticket price.
+//
+// The algorithms used for the blocks prior to the current tip are retained so
+// switching algorithms part way through a simulation results in a schedule
+// which is named accordingly and reflected in the results.
+func (s *simulator) setStakeDiffAlgorithm(algo *stakeDiffAlgorithm) {
+	var nextHeight int32
+	if s.tip != nil {
+		nextHeight = s.tip.height + 1
+	}
+
+	// Keep the algorithms that were active prior to the next block and
+	// then follow the passed algorithm from there on.
+	var sched stakeDiffSchedule
+	for _, activation := range s.algoSchedule {
+		if activation.height < nextHeight {
+			sched = append(sched, activation)
+		}
+	}
+	activations := algo.activations()
+	active := activations.activeAt(nextHeight)
+	if len(sched) == 0 || sched[len(sched)-1].algo != active {
+		sched = append(sched, stakeDiffActivation{
+			algo:   active,
+			height: nextHeight,
+		})
+	}
+	for _, activation := range activations {
+		if activation.height > nextHeight {
+			sched = append(sched, activation)
+		}
+	}
+	s.algoSchedule = sched
+
+	s.algoName = sched.String()
+	s.nextTicketPriceFunc = func() int64 {
+		var nextHeight int32
+		if s.tip != nil {
+			nextHeight = s.tip.height + 1
+		}
+		return sched.activeAt(nextHeight).calcFunc(s)
+	}
+}
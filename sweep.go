@@ -0,0 +1,405 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"math/rand"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxSweepPoints is the maximum number of points a parameter sweep may
+	// run.  It prevents a typo in a range from silently turning into a run
+	// that would take days.
+	maxSweepPoints = 10000
+
+	// sweepCSVFileName is the name of the file the results of a parameter
+	// sweep are written to in the output directory.
+	sweepCSVFileName = "sweep.csv"
+)
+
+// sweepParam houses a chain parameter that is varied by a parameter sweep along
+// with all of the values it takes.
+type sweepParam struct {
+	name   string
+	values []int64
+}
+
+// parseSweepSpec parses the passed comma-separated list of parameter ranges of
+// the form name=min:max[:step] and returns the associated sweep parameters.
+// The names are the same as the names of the fields in chaincfg.Params that
+// may be overridden by a custom parameters file and the step defaults to one.
+func parseSweepSpec(spec string) ([]sweepParam, error) {
+	overridesType := reflect.TypeOf(paramsOverrides{})
+	var params []sweepParam
+	seen := make(map[string]struct{})
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("sweep parameter %q is not of the "+
+				"form name=min:max[:step]", item)
+		}
+		name := strings.TrimSpace(parts[0])
+		field, ok := overridesType.FieldByName(name)
+		if !ok || field.Type.Elem().Kind() == reflect.String {
+			return nil, fmt.Errorf("sweep parameter %q is not a "+
+				"supported numeric chain parameter", name)
+		}
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("sweep parameter %q is specified "+
+				"more than once", name)
+		}
+		seen[name] = struct{}{}
+
+		bounds := strings.Split(parts[1], ":")
+		if len(bounds) < 2 || len(bounds) > 3 {
+			return nil, fmt.Errorf("range %q of sweep parameter %q is "+
+				"not of the form min:max[:step]", parts[1], name)
+		}
+		rangeVals := []int64{0, 0, 1}
+		for i, bound := range bounds {
+			val, err := strconv.ParseInt(strings.TrimSpace(bound), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid range %q for sweep "+
+					"parameter %q: %v", parts[1], name, err)
+			}
+			rangeVals[i] = val
+		}
+		min, max, step := rangeVals[0], rangeVals[1], rangeVals[2]
+		if min > max || step <= 0 {
+			return nil, fmt.Errorf("invalid range %q for sweep "+
+				"parameter %q -- min must not exceed max and step "+
+				"must be positive", parts[1], name)
+		}
+		numSteps := uint64(max-min) / uint64(step)
+		if numSteps >= maxSweepPoints {
+			return nil, fmt.Errorf("range %q of sweep parameter %q "+
+				"has more than %d values", parts[1], name,
+				maxSweepPoints)
+		}
+
+		param := sweepParam{name: name}
+		for i := uint64(0); i <= numSteps; i++ {
+			param.values = append(param.values, min+int64(i)*step)
+		}
+		params = append(params, param)
+	}
+	if len(params) == 0 {
+		return nil, fmt.Errorf("no parameters to sweep were specified")
+	}
+	return params, nil
+}
+
+// sweepGrid returns every combination of the values of the passed parameters.
+// The values of the last parameter vary the fastest.
+func sweepGrid(params []sweepParam) ([][]int64, error) {
+	numPoints := 1
+	for _, param := range params {
+		numPoints *= len(param.values)
+		if numPoints > maxSweepPoints {
+			return nil, fmt.Errorf("the sweep grid has more than %d "+
+				"points -- use sweepsamples to randomly sample it",
+				maxSweepPoints)
+		}
+	}
+	points := make([][]int64, 0, numPoints)
+	for i := 0; i < numPoints; i++ {
+		point := make([]int64, len(params))
+		idx := i
+		for j := len(params) - 1; j >= 0; j-- {
+			values := params[j].values
+			point[j] = values[idx%len(values)]
+			idx /= len(values)
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// sweepSamples returns the provided number of combinations of the values of
+// the passed parameters chosen independently and uniformly at random using
+// the passed random number generator.
+func sweepSamples(params []sweepParam, numSamples int, rng *rand.Rand) ([][]int64, error) {
+	if numSamples > maxSweepPoints {
+		return nil, fmt.Errorf("the number of sweep samples must not "+
+			"exceed %d", maxSweepPoints)
+	}
+	points := make([][]int64, 0, numSamples)
+	for i := 0; i < numSamples; i++ {
+		point := make([]int64, len(params))
+		for j, param := range params {
+			point[j] = param.values[rng.Intn(len(param.values))]
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// sweepOverrides returns the chain parameter overrides that set each of the
+// passed parameters to the associated value of the provided point.
+func sweepOverrides(params []sweepParam, point []int64) (*paramsOverrides, error) {
+	var overrides paramsOverrides
+	fields := reflect.ValueOf(&overrides).Elem()
+	for i, param := range params {
+		field := fields.FieldByName(param.name)
+		val := reflect.New(field.Type().Elem())
+		switch val.Elem().Kind() {
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if point[i] < 0 || val.Elem().OverflowUint(uint64(point[i])) {
+				return nil, fmt.Errorf("%s value %d is out of range",
+					param.name, point[i])
+			}
+			val.Elem().SetUint(uint64(point[i]))
+		default:
+			if val.Elem().OverflowInt(point[i]) {
+				return nil, fmt.Errorf("%s value %d is out of range",
+					param.name, point[i])
+			}
+			val.Elem().SetInt(point[i])
+		}
+		field.Set(val)
+	}
+	return &overrides, nil
+}
+
+// sweepRun houses the results of running the simulation at a single point of a
+// parameter sweep.  The error is set instead of the summary when the point
+// could not be simulated, for example, because the parameters are invalid.
+type sweepRun struct {
+	point   []int64
+	summary *resultsSummary
+	err     error
+}
+
+// runSweep runs the simulation described by the configuration at each of the
+// passed points of the parameter sweep and returns the results in the same
+// order.  Every run uses the same seed so the only difference between them is
+// the value of the swept parameters.
+func (cfg *simConfig) runSweep(params []sweepParam, points [][]int64) []sweepRun {
+	baseParams := cfg.params
+	defer func() { cfg.params = baseParams }()
+
+	runs := make([]sweepRun, 0, len(points))
+	for i, point := range points {
+		run := sweepRun{point: point}
+		fmt.Printf("Running sweep point %d of %d (%s)", i+1, len(points),
+			formatSweepPoint(params, point))
+		overrides, err := sweepOverrides(params, point)
+		if err == nil {
+			cfg.params = overrides.apply(baseParams)
+			err = validateParams(cfg.params)
+		}
+		var sim *simulator
+		if err == nil {
+			sim, err = cfg.newSimulator()
+		}
+		if err == nil {
+			sim.quiet = true
+			err = cfg.run(sim, nil)
+		}
+		if err != nil {
+			fmt.Printf(": %v\n", err)
+			run.err = err
+			runs = append(runs, run)
+			continue
+		}
+		run.summary = sim.summarizeResults()
+		fmt.Printf(": pool size MAD %.2f, volatility %.2f%%\n",
+			run.summary.Stability.PoolSizeMAD,
+			run.summary.Stability.PriceVolatility*100)
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// formatSweepPoint returns the passed point of a parameter sweep as a
+// human-readable list of name=value pairs.
+func formatSweepPoint(params []sweepParam, point []int64) string {
+	pairs := make([]string, 0, len(params))
+	for i, param := range params {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", param.name, point[i]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// writeSweepCSV writes the swept parameter values and the stability metrics of
+// every run of a parameter sweep to the file at the provided path in the order
+// they were run.  The metrics of runs that failed are left empty and the error
+// is recorded instead.
+func writeSweepCSV(path string, params []sweepParam, runs []sweepRun) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := make([]string, 0, len(params)+10)
+	for _, param := range params {
+		header = append(header, param.name)
+	}
+	header = append(header, "pricevolatility", "clampedwindows",
+		"numwindows", "poolsizemad", "oscillationperiod", "numshocks",
+		"convergencetime", "numunconverged", "minpoolsize", "maxpoolsize",
+		"error")
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	for _, run := range runs {
+		record := make([]string, 0, len(header))
+		for _, val := range run.point {
+			record = append(record, strconv.FormatInt(val, 10))
+		}
+		if run.err != nil {
+			for len(record) < len(header)-1 {
+				record = append(record, "")
+			}
+			record = append(record, run.err.Error())
+		} else {
+			m := &run.summary.Stability
+			record = append(record,
+				formatFloat(m.PriceVolatility),
+				strconv.FormatUint(uint64(m.ClampedWindows), 10),
+				strconv.FormatUint(uint64(m.NumWindows), 10),
+				formatFloat(m.PoolSizeMAD),
+				formatFloat(m.OscillationPeriod),
+				strconv.Itoa(m.NumShocks),
+				formatFloat(m.ConvergenceTime),
+				strconv.Itoa(m.NumUnconverged),
+				strconv.FormatUint(uint64(run.summary.MinPoolSize), 10),
+				strconv.FormatUint(uint64(run.summary.MaxPoolSize), 10),
+				"")
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// sweepRow is a single row of the table in the parameter sweep results.
+type sweepRow struct {
+	Rank    string
+	Values  []string
+	Metrics []string
+	Error   string
+}
+
+// rankedSweepRows returns the rows of the table in the parameter sweep results
+// ranked from the most to the least stable as measured by the mean absolute
+// deviation of the pool size from the target.  Runs that failed are listed
+// last.
+func rankedSweepRows(runs []sweepRun) []sweepRow {
+	ranked := make([]sweepRun, len(runs))
+	copy(ranked, runs)
+	sort.Stable(sweepRunsByMAD(ranked))
+
+	rows := make([]sweepRow, 0, len(ranked))
+	for i, run := range ranked {
+		row := sweepRow{Rank: strconv.Itoa(i + 1)}
+		for _, val := range run.point {
+			row.Values = append(row.Values, strconv.FormatInt(val, 10))
+		}
+		if run.err != nil {
+			row.Rank = "-"
+			row.Error = run.err.Error()
+			rows = append(rows, row)
+			continue
+		}
+		for _, metric := range run.summary.Stability.formatted() {
+			row.Metrics = append(row.Metrics, metric.Value)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// sweepRunsByMAD implements sort.Interface to allow a slice of sweep runs to be
+// sorted by the mean absolute deviation of their pool size from the target with
+// failed runs last.
+type sweepRunsByMAD []sweepRun
+
+// Len returns the number of runs in the slice.  It is part of the
+// sort.Interface implementation.
+func (s sweepRunsByMAD) Len() int {
+	return len(s)
+}
+
+// Swap swaps the runs at the passed indices.  It is part of the sort.Interface
+// implementation.
+func (s sweepRunsByMAD) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// Less returns whether the run with index i should sort before the run with
+// index j.  It is part of the sort.Interface implementation.
+func (s sweepRunsByMAD) Less(i, j int) bool {
+	if s[i].err != nil || s[j].err != nil {
+		return s[i].err == nil && s[j].err != nil
+	}
+	return s[i].summary.Stability.PoolSizeMAD <
+		s[j].summary.Stability.PoolSizeMAD
+}
+
+// generateSweepResults creates an HTML results file at the provided path that
+// tabulates the stability metrics of every run of a parameter sweep.
+func generateSweepResults(cfg *simConfig, params []sweepParam, runs []sweepRun, resultsPath string) error {
+	// Parse the sweep template along with the results template which
+	// defines the shared portions.
+	tpl, err := template.New("results").Parse(resultsTmplText)
+	if err != nil {
+		return fmt.Errorf("unable to parse results template: %v", err)
+	}
+	tpl, err = tpl.New("sweep").Parse(sweepTmplText)
+	if err != nil {
+		return fmt.Errorf("unable to parse sweep template: %v", err)
+	}
+	resultsFile, err := os.Create(resultsPath)
+	if err != nil {
+		return fmt.Errorf("unable to create results: %v", err)
+	}
+	defer resultsFile.Close()
+
+	paramNames := make([]string, 0, len(params))
+	for _, param := range params {
+		paramNames = append(paramNames, param.name)
+	}
+	var metricNames []string
+	for _, metric := range (&stabilityMetrics{}).formatted() {
+		metricNames = append(metricNames, metric.Name)
+	}
+	err = tpl.Execute(resultsFile, map[string]interface{}{
+		"Algorithm":   cfg.algo.name,
+		"Network":     cfg.params.Name,
+		"Seed":        strconv.FormatInt(cfg.seed, 10),
+		"NumRuns":     len(runs),
+		"ParamNames":  paramNames,
+		"MetricNames": metricNames,
+		"Rows":        rankedSweepRows(runs),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to execute template: %v", err)
+	}
+
+	return resultsFile.Close()
+}
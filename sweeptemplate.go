@@ -0,0 +1,66 @@
+package main
+
+var sweepTmplText = `
+<!doctype html>
+<html lang="en">
+  <head>
+    <meta charset="utf-8">
+    <meta http-equiv="X-UA-Compatible" content="IE=edge">
+    <title>Parameter Sweep Results</title>
+    {{template "libs"}}
+  </head>
+  <body style="margin: 0px; padding: 0px;">
+    <div id="container" style="width: 100%;">
+      {{template "navbar"}}
+      <div style="width: 95%;">
+        <table>
+          <tr>
+            <th>Stake Difficulty Algorithm</th>
+            <th>{{.Algorithm}}</th>
+          </tr>
+          <tr>
+            <th>Network</th>
+            <th>{{.Network}}</th>
+          </tr>
+          <tr>
+            <th>Random Seed</th>
+            <th>{{.Seed}}</th>
+          </tr>
+          <tr>
+            <th>Number of Runs</th>
+            <th>{{.NumRuns}}</th>
+          </tr>
+        </table>
+        <p>Runs are ranked from the most to the least stable pool size.</p>
+        <table>
+          <tr>
+            <th>Rank</th>
+            {{range .ParamNames}}
+            <th>{{.}}</th>
+            {{end}}
+            {{range .MetricNames}}
+            <th>{{.}}</th>
+            {{end}}
+          </tr>
+          {{$numMetrics := len .MetricNames}}
+          {{range $row := .Rows}}
+          <tr>
+            <td>{{$row.Rank}}</td>
+            {{range $row.Values}}
+            <td>{{.}}</td>
+            {{end}}
+            {{if $row.Error}}
+            <td colspan="{{$numMetrics}}">{{$row.Error}}</td>
+            {{else}}
+            {{range $row.Metrics}}
+            <td>{{.}}</td>
+            {{end}}
+            {{end}}
+          </tr>
+          {{end}}
+        </table>
+      </div>
+    </div>
+  </body>
+</html>
+`
@@ -0,0 +1,191 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/decred/dcrd/wire"
+)
+
+const (
+	// validationCSVFileName is the name of the file the anomalies found in
+	// the CSV input data by a lenient replay are written to in the output
+	// directory.
+	validationCSVFileName = "validation.csv"
+
+	// maxReportedAnomalies is the max number of individual anomalies that
+	// are written to the validation report.  All of them are written to
+	// the validation CSV in the output directory.
+	maxReportedAnomalies = 20
+)
+
+// Actions taken by a lenient replay in response to an anomaly in the CSV input
+// data.
+const (
+	actionSkipped   = "skipped row"
+	actionAssumed   = "assumed next height"
+	actionGenerated = "generated ticket hashes"
+	actionStopped   = "stopped replay"
+	actionUnchecked = "not replayed"
+)
+
+// csvAnomaly describes a problem with a single row of the CSV input data along
+// with the action a lenient replay took in response to it.
+type csvAnomaly struct {
+	line        int
+	height      int32
+	field       string
+	description string
+	action      string
+}
+
+// replayValidator records the anomalies found in the CSV input data while it is
+// replayed leniently.  Rows that can be safely skipped or repaired are and the
+// replay continues.  Otherwise, the replay stops at the row, however, the rest
+// of the rows are still checked so every anomaly in the data is reported.
+type replayValidator struct {
+	numRows     uint64
+	nextHeight  int32
+	anomalies   []csvAnomaly
+	stoppedLine int
+}
+
+// newReplayValidator returns a new replay validator which is ready for use.
+func newReplayValidator() *replayValidator {
+	return &replayValidator{}
+}
+
+// record records an anomaly in the row at the passed line and height of the CSV
+// input data along with the action that was taken in response.  The action for
+// anomalies found after the replay has stopped is always that the row was not
+// replayed.
+func (v *replayValidator) record(line int, height int32, field, action, format string, args ...interface{}) {
+	if v.stopped() {
+		action = actionUnchecked
+	}
+	v.anomalies = append(v.anomalies, csvAnomaly{
+		line:        line,
+		height:      height,
+		field:       field,
+		description: fmt.Sprintf(format, args...),
+		action:      action,
+	})
+	if action == actionStopped {
+		v.stoppedLine = line
+	}
+}
+
+// stopped returns whether or not an anomaly caused the replay to stop.
+func (v *replayValidator) stopped() bool {
+	return v.stoppedLine != 0
+}
+
+// convertRecord converts the passed record from the CSV input data at the given
+// line into simulation data while recording any anomalies in it.  Nil is
+// returned when the row must not be replayed, which is the case for rows that
+// are out of order and for every row once the replay has stopped.
+//
+// Rows that are missing heights or have an undecodable header stop the replay
+// since the state of the ticket pool is unknown from then on.  Rows with
+// invalid ticket hashes are replayed with generated hashes instead.
+func (v *replayValidator) convertRecord(record []string, line int) (*simData, *wire.BlockHeader) {
+	v.numRows++
+
+	// Ensure the height of the row is the next one in the sequence.  Rows
+	// with an unparsable height are assumed to be the next one.
+	height := v.nextHeight
+	recordHeight, err := strconv.ParseInt(record[0], 10, 32)
+	switch {
+	case err != nil:
+		v.record(line, height, "height", actionAssumed,
+			"unable to parse %q", record[0])
+
+	case int32(recordHeight) < height:
+		v.record(line, int32(recordHeight), "height", actionSkipped,
+			"duplicate or out of order height, expected %d", height)
+		return nil, nil
+
+	case int32(recordHeight) > height:
+		missing := strconv.FormatInt(int64(height), 10)
+		if int32(recordHeight)-height > 1 {
+			missing += fmt.Sprintf(" to %d", recordHeight-1)
+		}
+		v.record(line, int32(recordHeight), "height", actionStopped,
+			"missing height %s", missing)
+		height = int32(recordHeight)
+	}
+	v.nextHeight = height + 1
+
+	headerBytes, header, err := decodeHeader(record[1])
+	if err != nil {
+		v.record(line, height, "header", actionStopped, "%v", err)
+		return nil, nil
+	}
+	ticketHashes, err := decodeTicketHashes(record[2], header.FreshStake)
+	if err != nil {
+		v.record(line, height, "ticketHashes", actionGenerated, "%v",
+			err)
+	}
+	if v.stopped() {
+		return nil, nil
+	}
+	return newSimData(headerBytes, header, ticketHashes), header
+}
+
+// writeReport writes a summary of the validation results, including the first
+// anomalies that were found, to the passed writer.
+func (v *replayValidator) writeReport(w io.Writer) {
+	fmt.Fprintf(w, "Validated %d rows of CSV input data: ", v.numRows)
+	if len(v.anomalies) == 0 {
+		fmt.Fprintln(w, "no anomalies")
+		return
+	}
+	fmt.Fprintf(w, "%d anomalies\n", len(v.anomalies))
+	if v.stopped() {
+		fmt.Fprintf(w, "The replay stopped at line %d\n", v.stoppedLine)
+	}
+
+	for i, anomaly := range v.anomalies {
+		if i == maxReportedAnomalies {
+			fmt.Fprintf(w, "  ... and %d more\n",
+				len(v.anomalies)-maxReportedAnomalies)
+			break
+		}
+		fmt.Fprintf(w, "  line %d (height %d): invalid %s: %s (%s)\n",
+			anomaly.line, anomaly.height, anomaly.field,
+			anomaly.description, anomaly.action)
+	}
+}
+
+// writeCSV writes every recorded anomaly to a CSV file at the provided path.
+func (v *replayValidator) writeCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"line", "height", "field", "description", "action"})
+	for _, anomaly := range v.anomalies {
+		w.Write([]string{
+			strconv.Itoa(anomaly.line),
+			strconv.FormatInt(int64(anomaly.height), 10),
+			anomaly.field,
+			anomaly.description,
+			anomaly.action,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/decred/dcrd/wire"
+)
+
+// headerMismatch describes a single field of a simulated block that does not
+// match the associated field in the actual block header.
+type headerMismatch struct {
+	height   int32
+	field    string
+	expected string
+	actual   string
+}
+
+// replayVerifier compares the state of a simulation driven by mainnet data
+// against the fields committed to by the actual block headers and tracks any
+// divergence between the two.
+type replayVerifier struct {
+	numVerified     uint64
+	numMismatched   uint64
+	firstDivergence []headerMismatch
+	fieldMismatches map[string]uint64
+}
+
+// newReplayVerifier returns a new replay verifier which is ready for use.
+func newReplayVerifier() *replayVerifier {
+	return &replayVerifier{
+		fieldMismatches: make(map[string]uint64),
+	}
+}
+
+// verifiedFields is the list of header fields that are verified in the order
+// they are reported.
+var verifiedFields = []string{"Height", "SBits", "PoolSize", "FinalState"}
+
+// verifyNode compares the passed simulated block node against the actual block
+// header it was created from and records any mismatches.
+func (v *replayVerifier) verifyNode(node *blockNode, header *wire.BlockHeader) {
+	var mismatches []headerMismatch
+	check := func(field, expected, actual string) {
+		if expected != actual {
+			mismatches = append(mismatches, headerMismatch{
+				height:   node.height,
+				field:    field,
+				expected: expected,
+				actual:   actual,
+			})
+		}
+	}
+	check("Height", strconv.FormatUint(uint64(header.Height), 10),
+		strconv.FormatInt(int64(node.height), 10))
+	check("SBits", strconv.FormatInt(header.SBits, 10),
+		strconv.FormatInt(node.ticketPrice, 10))
+	check("PoolSize", strconv.FormatUint(uint64(header.PoolSize), 10),
+		strconv.FormatUint(uint64(node.poolSize), 10))
+	check("FinalState", fmt.Sprintf("%x", header.FinalState[:]),
+		fmt.Sprintf("%x", node.finalState[:]))
+
+	v.numVerified++
+	if len(mismatches) == 0 {
+		return
+	}
+	v.numMismatched++
+	if v.firstDivergence == nil {
+		v.firstDivergence = mismatches
+	}
+	for _, mismatch := range mismatches {
+		v.fieldMismatches[mismatch.field]++
+	}
+}
+
+// writeReport writes a summary of the verification results, including the
+// first block that diverged from the actual data, to the passed writer.
+func (v *replayVerifier) writeReport(w io.Writer) {
+	fmt.Fprintf(w, "Verified %d blocks against their headers: ",
+		v.numVerified)
+	if v.numMismatched == 0 {
+		fmt.Fprintln(w, "all fields match")
+		return
+	}
+	fmt.Fprintf(w, "%d blocks do not match\n", v.numMismatched)
+
+	fmt.Fprintf(w, "First divergence at height %d:\n",
+		v.firstDivergence[0].height)
+	for _, mismatch := range v.firstDivergence {
+		fmt.Fprintf(w, "  %-10s expected %s, simulated %s\n",
+			mismatch.field, mismatch.expected, mismatch.actual)
+	}
+
+	fmt.Fprintln(w, "Mismatches per field:")
+	for _, field := range verifiedFields {
+		fmt.Fprintf(w, "  %-10s %d\n", field, v.fieldMismatches[field])
+	}
+}
+
+// failed returns whether or not any of the verified blocks did not match.
+func (v *replayVerifier) failed() bool {
+	return v.numMismatched != 0
+}
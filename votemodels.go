@@ -0,0 +1,194 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// missedVoteModel describes a model for how many of the tickets selected by the
+// lottery actually vote in a block as opposed to missing their vote.
+type missedVoteModel interface {
+	// numVotes returns how many of the passed number of tickets that were
+	// selected to vote in the block at the given height actually vote.
+	numVotes(nextHeight int32, ticketsPerBlock uint16, rng *rand.Rand) uint16
+}
+
+// voteModelConfig houses the configuration used to create a missed vote model.
+type voteModelConfig struct {
+	// Model is the name of the missed vote model.
+	Model string `json:"model"`
+
+	// MissRate is the fraction of selected tickets that miss their vote.
+	// It applies to the fixed, random, and outage models.
+	MissRate float64 `json:"missrate"`
+
+	// OutageProb is the probability that an outage starts in any given
+	// block.  It only applies to the outage model.
+	OutageProb float64 `json:"outageprob"`
+
+	// OutageLength is the number of blocks an outage lasts.  It only
+	// applies to the outage model.
+	OutageLength uint32 `json:"outagelength"`
+
+	// OutageMissRate is the fraction of selected tickets that miss their
+	// vote while an outage is in progress.  It only applies to the outage
+	// model.
+	OutageMissRate float64 `json:"outagemissrate"`
+}
+
+// minVotes returns the minimum number of votes a block must contain in order
+// to be valid given the number of tickets that are selected per block.
+func minVotes(ticketsPerBlock uint16) uint16 {
+	return ticketsPerBlock/2 + 1
+}
+
+// clampVotes limits the passed number of votes to the range that is valid for
+// a block given the number of tickets that are selected per block.  Blocks
+// with fewer votes than the minimum can't be mined, so, in practice, miners
+// wait for enough votes to build a block.
+func clampVotes(votes int, ticketsPerBlock uint16) uint16 {
+	if votes < int(minVotes(ticketsPerBlock)) {
+		return minVotes(ticketsPerBlock)
+	}
+	if votes > int(ticketsPerBlock) {
+		return ticketsPerBlock
+	}
+	return uint16(votes)
+}
+
+// randomMisses returns how many of the passed number of tickets miss their vote
+// when each one independently misses with the provided probability.
+func randomMisses(numTickets uint16, missRate float64, rng *rand.Rand) int {
+	var misses int
+	for i := uint16(0); i < numTickets; i++ {
+		if rng.Float64() < missRate {
+			misses++
+		}
+	}
+	return misses
+}
+
+// perfectVoteModel is a missed vote model where every selected ticket votes.
+type perfectVoteModel struct{}
+
+// numVotes returns the passed number of tickets per block since no votes are
+// ever missed.
+//
+// This is part of the missedVoteModel interface.
+func (m *perfectVoteModel) numVotes(nextHeight int32, ticketsPerBlock uint16, rng *rand.Rand) uint16 {
+	return ticketsPerBlock
+}
+
+// fixedMissVoteModel is a missed vote model where a fixed fraction of the
+// selected tickets miss their vote.  The fractional misses are carried forward
+// so that the overall miss rate matches the configured rate exactly over time.
+type fixedMissVoteModel struct {
+	missRate        float64
+	pendingFraction float64
+}
+
+// numVotes returns the number of tickets that vote after deducting the misses
+// accumulated according to the fixed miss rate.
+//
+// This is part of the missedVoteModel interface.
+func (m *fixedMissVoteModel) numVotes(nextHeight int32, ticketsPerBlock uint16, rng *rand.Rand) uint16 {
+	m.pendingFraction += m.missRate * float64(ticketsPerBlock)
+	misses := int(m.pendingFraction)
+	m.pendingFraction -= float64(misses)
+	return clampVotes(int(ticketsPerBlock)-misses, ticketsPerBlock)
+}
+
+// randomMissVoteModel is a missed vote model where each selected ticket
+// independently misses its vote with a given probability.
+type randomMissVoteModel struct {
+	missRate float64
+}
+
+// numVotes returns the number of tickets that vote after randomly determining
+// which ones miss.
+//
+// This is part of the missedVoteModel interface.
+func (m *randomMissVoteModel) numVotes(nextHeight int32, ticketsPerBlock uint16, rng *rand.Rand) uint16 {
+	misses := randomMisses(ticketsPerBlock, m.missRate, rng)
+	return clampVotes(int(ticketsPerBlock)-misses, ticketsPerBlock)
+}
+
+// outageVoteModel is a missed vote model where selected tickets randomly miss
+// their votes at a base rate and outages, such as a large stake pool going
+// offline, randomly occur and cause a much higher rate of misses for a period
+// of time.
+type outageVoteModel struct {
+	missRate       float64
+	outageProb     float64
+	outageLength   uint32
+	outageMissRate float64
+	outageEnd      int32
+}
+
+// numVotes returns the number of tickets that vote after randomly determining
+// which ones miss according to whether or not an outage is in progress.
+//
+// This is part of the missedVoteModel interface.
+func (m *outageVoteModel) numVotes(nextHeight int32, ticketsPerBlock uint16, rng *rand.Rand) uint16 {
+	if nextHeight >= m.outageEnd && rng.Float64() < m.outageProb {
+		m.outageEnd = nextHeight + int32(m.outageLength)
+	}
+
+	missRate := m.missRate
+	if nextHeight < m.outageEnd {
+		missRate = m.outageMissRate
+	}
+	misses := randomMisses(ticketsPerBlock, missRate, rng)
+	return clampVotes(int(ticketsPerBlock)-misses, ticketsPerBlock)
+}
+
+// validateRate returns an error when the passed rate is not in the range
+// [0, 1].
+func validateRate(name string, rate float64) error {
+	if rate < 0 || rate > 1 {
+		return fmt.Errorf("%s must be between 0 and 1 -- got %v", name,
+			rate)
+	}
+	return nil
+}
+
+// newMissedVoteModel returns a missed vote model created according to the
+// passed configuration.
+func newMissedVoteModel(cfg *voteModelConfig) (missedVoteModel, error) {
+	if err := validateRate("missrate", cfg.MissRate); err != nil {
+		return nil, err
+	}
+
+	switch cfg.Model {
+	case "", "perfect":
+		return &perfectVoteModel{}, nil
+
+	case "fixed":
+		return &fixedMissVoteModel{missRate: cfg.MissRate}, nil
+
+	case "random":
+		return &randomMissVoteModel{missRate: cfg.MissRate}, nil
+
+	case "outage":
+		if err := validateRate("outageprob", cfg.OutageProb); err != nil {
+			return nil, err
+		}
+		err := validateRate("outagemissrate", cfg.OutageMissRate)
+		if err != nil {
+			return nil, err
+		}
+		return &outageVoteModel{
+			missRate:       cfg.MissRate,
+			outageProb:     cfg.OutageProb,
+			outageLength:   cfg.OutageLength,
+			outageMissRate: cfg.OutageMissRate,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown missed vote model %q -- available "+
+		"models are perfect, fixed, random, and outage", cfg.Model)
+}